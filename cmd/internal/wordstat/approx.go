@@ -0,0 +1,231 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"io"
+	"iter"
+	"regexp"
+	"slices"
+)
+
+const defaultApproxCap = 1024
+
+// SpaceSaving (Metwally et al.): не больше capacity ключей, новый ключ вытесняет
+// минимальный и наследует его count как ошибку: count-error <= истина <= count.
+type SpaceSaving struct {
+	capacity int
+	total    int
+	idx      map[string]int
+	items    []ssItem // min-heap по count
+}
+
+type ssItem struct {
+	key        string
+	count, err int
+}
+
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SpaceSaving{capacity: capacity, idx: make(map[string]int, capacity)}
+}
+
+func (s *SpaceSaving) Add(key string) { s.AddN(key, 1) }
+
+func (s *SpaceSaving) add(b []byte) {
+	if i, ok := s.idx[string(b)]; ok {
+		s.total++
+		s.items[i].count++
+		s.down(i)
+		return
+	}
+	s.AddN(string(b), 1)
+}
+
+func (s *SpaceSaving) AddN(key string, n int) {
+	if key == "" || n <= 0 {
+		return
+	}
+	s.total += n
+	if i, ok := s.idx[key]; ok {
+		s.items[i].count += n
+		s.down(i)
+		return
+	}
+	if len(s.items) < s.capacity {
+		s.items = append(s.items, ssItem{key: key, count: n})
+		s.idx[key] = len(s.items) - 1
+		s.up(len(s.items) - 1)
+		return
+	}
+	min := s.items[0]
+	delete(s.idx, min.key)
+	s.items[0] = ssItem{key: key, count: min.count + n, err: min.count}
+	s.idx[key] = 0
+	s.down(0)
+}
+
+// Merge - mergeable summaries (Agarwal et al.): ключу, которого нет в полной
+// сводке, добавляется её минимальный count (и к ошибке тоже).
+func (s *SpaceSaving) Merge(o Counter) error {
+	other, ok := o.(*SpaceSaving)
+	if !ok {
+		for w, n := range o.Range {
+			s.AddN(w, n)
+		}
+		return nil
+	}
+
+	floor, otherFloor := s.floor(), other.floor()
+	items := make([]ssItem, 0, len(s.items)+len(other.items))
+	for _, it := range s.items {
+		if j, ok := other.idx[it.key]; ok {
+			it.count += other.items[j].count
+			it.err += other.items[j].err
+		} else {
+			it.count += otherFloor
+			it.err += otherFloor
+		}
+		items = append(items, it)
+	}
+	for _, it := range other.items {
+		if _, ok := s.idx[it.key]; !ok {
+			it.count += floor
+			it.err += floor
+			items = append(items, it)
+		}
+	}
+	slices.SortFunc(items, func(a, b ssItem) int { return cmp.Compare(b.count, a.count) })
+
+	s.items = items[:min(len(items), s.capacity)]
+	clear(s.idx)
+	for i := range s.items {
+		s.idx[s.items[i].key] = i
+	}
+	for i := len(s.items)/2 - 1; i >= 0; i-- {
+		s.down(i)
+	}
+	s.total += other.total
+	return nil
+}
+
+func (s *SpaceSaving) floor() int {
+	if len(s.items) < s.capacity {
+		return 0
+	}
+	return s.items[0].count
+}
+
+func (s *SpaceSaving) Total() int { return s.total }
+
+func (s *SpaceSaving) Len() int { return len(s.items) }
+
+func (s *SpaceSaving) Range(yield func(key string, n int) bool) {
+	for _, it := range s.items {
+		if !yield(it.key, it.count) {
+			return
+		}
+	}
+}
+
+// Entries - ключи с оценками и ошибками, без сортировки.
+func (s *SpaceSaving) Entries() []Entry {
+	entries := make([]Entry, 0, len(s.items))
+	for _, it := range s.items {
+		entries = append(entries, Entry{Word: it.key, Count: it.count, Error: it.err})
+	}
+	return entries
+}
+
+func (s *SpaceSaving) less(i, j int) bool { return s.items[i].count < s.items[j].count }
+
+func (s *SpaceSaving) swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.idx[s.items[i].key] = i
+	s.idx[s.items[j].key] = j
+}
+
+func (s *SpaceSaving) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !s.less(i, p) {
+			return
+		}
+		s.swap(i, p)
+		i = p
+	}
+}
+
+func (s *SpaceSaving) down(i int) {
+	for {
+		l := 2*i + 1
+		if l >= len(s.items) {
+			return
+		}
+		m := l
+		if r := l + 1; r < len(s.items) && s.less(r, l) {
+			m = r
+		}
+		if !s.less(m, i) {
+			return
+		}
+		s.swap(i, m)
+		i = m
+	}
+}
+
+func approxCapacity(opts Options) int {
+	if opts.ApproxCap > 0 {
+		return opts.ApproxCap
+	}
+	return max(defaultApproxCap, 10*opts.K)
+}
+
+// стоп-слова отбрасываются до того, как займут место в сводке
+type stopFilter struct {
+	next keyAdder
+	sw   Stopwords
+	n    int
+}
+
+func (f stopFilter) add(key []byte) {
+	if len(key) == 0 || isStopKey(key, f.sw, f.n) {
+		return
+	}
+	f.next.add(key)
+}
+
+func approxEntries(ctx context.Context, docs iter.Seq2[Document, error], tok Tokenizer, re *regexp.Regexp, opts Options) ([]Entry, error) {
+	ss := NewSpaceSaving(approxCapacity(opts))
+	var sink keyAdder = ss
+	if len(opts.Stopwords) > 0 {
+		sink = stopFilter{next: ss, sw: opts.Stopwords, n: opts.Ngram}
+	}
+	for doc, err := range docs {
+		if err != nil {
+			return nil, err
+		}
+		reportEngine(streamChoice(doc, "stream", "-approx"), opts)
+		r := doc.R
+		if doc.Data != nil {
+			r = bytes.NewReader(doc.Data)
+		}
+		if err := streamDocument(ctx, r, tok, re, opts, sink); err != nil {
+			return nil, err
+		}
+	}
+	opts.Stopwords = nil // уже отброшены в sink
+	return counterEntries(ss, opts), nil
+}
+
+func streamDocument(ctx context.Context, r io.Reader, tok Tokenizer, re *regexp.Regexp, opts Options, sink keyAdder) error {
+	in := bufio.NewReader(r)
+	if re != nil {
+		return patternKeys(ctx, in, re, opts.Group, tok, opts.Ngram, sink)
+	}
+	return scanKeys(ctx, in, tok, opts.Ngram, sink)
+}
@@ -0,0 +1,153 @@
+package wordstat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpaceSaving_Bounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	zipf := rand.NewZipf(rng, 1.2, 1, 5000)
+
+	const capacity = 100
+	ss := NewSpaceSaving(capacity)
+	exact := map[string]int{}
+	for i := 0; i < 50_000; i++ {
+		w := fmt.Sprint("w", zipf.Uint64())
+		ss.Add(w)
+		exact[w]++
+	}
+
+	if ss.Total() != 50_000 {
+		t.Fatalf("Total()=%d", ss.Total())
+	}
+	got := map[string]Entry{}
+	for _, e := range ss.Entries() {
+		got[e.Word] = e
+		if n := exact[e.Word]; e.Count-e.Error > n || n > e.Count {
+			t.Fatalf("%s: true count %d outside [%d, %d]", e.Word, n, e.Count-e.Error, e.Count)
+		}
+	}
+	if len(got) != capacity {
+		t.Fatalf("monitored %d keys, want %d", len(got), capacity)
+	}
+	for w, c := range exact {
+		if _, ok := got[w]; c > ss.Total()/capacity && !ok {
+			t.Fatalf("%s seen %d times (> total/capacity) is not monitored", w, c)
+		}
+	}
+}
+
+func TestSpaceSaving_ExactWhenLargeEnough(t *testing.T) {
+	ss := NewSpaceSaving(10)
+	for _, w := range strings.Fields("a b a c a b") {
+		ss.Add(w)
+	}
+	entries := ss.Entries()
+	SortEntries(entries, Options{SortBy: "count"})
+	want := []Entry{{Word: "a", Count: 3}, {Word: "b", Count: 2}, {Word: "c", Count: 1}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got=%+v want=%+v", entries, want)
+	}
+}
+
+func TestRun_Approx(t *testing.T) {
+	// "the" and "cat" dominate a long tail of distinct words
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "the cat the tail%d ", i)
+	}
+	input := sb.String()
+
+	for _, opts := range []Options{
+		{SortBy: "count", K: 2, Approx: true, ApproxCap: 16},
+		{SortBy: "count", K: 2, Approx: true, ApproxCap: 16, Workers: 4, Buffered: true},
+		{SortBy: "count", K: 2, Approx: true, ApproxCap: 16, Pattern: `\w+`},
+	} {
+		var out strings.Builder
+		if err := Run(strings.NewReader(input), &out, opts); err != nil {
+			t.Fatalf("Run(%+v) error = %v", opts, err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "the ") || !strings.HasPrefix(lines[1], "cat ") {
+			t.Fatalf("Run(%+v) got:\n%s", opts, out.String())
+		}
+	}
+
+	// stopwords never take a place in the summary
+	var out strings.Builder
+	opts := Options{SortBy: "count", Format: "json", K: 1, Approx: true, ApproxCap: 4, Stopwords: Stopwords{"the": {}}}
+	if err := Run(strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got []Entry
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("bad json: %v out=%q", err, out.String())
+	}
+	if len(got) != 1 || got[0].Word != "cat" || got[0].Count-got[0].Error > 2000 || got[0].Count < 2000 {
+		t.Fatalf("got=%+v", got)
+	}
+}
+
+func TestValidateOptions_Approx(t *testing.T) {
+	for _, opts := range []Options{
+		{SortBy: "word", Workers: 1, Approx: true, Stem: "en"},
+		{SortBy: "word", Workers: 1, Approx: true, ApproxCap: -1},
+	} {
+		if err := ValidateOptions(opts); err == nil {
+			t.Fatalf("ValidateOptions(%+v): expected error", opts)
+		}
+	}
+}
+
+func TestHTTPWordstat_Approx(t *testing.T) {
+	h := NewHTTPMux()
+
+	input := strings.Repeat("a b a c ", 10) + "d e f g h"
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&format=json&k=1&approx=true&approxcap=3", strings.NewReader(input))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	var got []Entry
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("bad json: %v body=%q", err, rr.Body.String())
+	}
+	if len(got) != 1 || got[0].Word != "a" || got[0].Count < 20 || got[0].Count-got[0].Error > 20 {
+		t.Fatalf("got=%+v", got)
+	}
+
+	for _, q := range []string{"approx=maybe", "approxcap=-1", "approxcap=99999999", "approx=true&stem=en"} {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?"+q, strings.NewReader("a"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status=%d want=%d", q, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHTTPWordstat_ApproxBodyLimit(t *testing.T) {
+	h := NewHTTPMuxWithConfig(HTTPConfig{MaxBodyBytes: 16, MaxApproxBodyBytes: 1 << 10})
+	body := strings.Repeat("word ", 20)
+
+	for q, want := range map[string]int{
+		"approx=true":  http.StatusOK,
+		"approx=false": http.StatusRequestEntityTooLarge,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?"+q, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: status=%d want=%d body=%q", q, rr.Code, want, rr.Body.String())
+		}
+	}
+}
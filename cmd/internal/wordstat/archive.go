@@ -0,0 +1,73 @@
+package wordstat
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// заголовок tar
+const archiveSniffLen = 512
+
+// DetectArchive: "zip", "tar" или ""; сжатый tar сначала распаковать.
+func DetectArchive(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte("PK\x03\x04")), bytes.HasPrefix(sample, []byte("PK\x05\x06")):
+		return "zip"
+	case len(sample) >= archiveSniffLen && bytes.Equal(sample[257:262], []byte("ustar")):
+		return "tar"
+	}
+	return ""
+}
+
+// документ читается только до следующего
+func TarDocuments(r io.Reader, keep func(name string) bool) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Document{}, fmt.Errorf("read tar: %w", err))
+				return
+			}
+			if hdr.Typeflag != tar.TypeReg || (keep != nil && !keep(hdr.Name)) {
+				continue
+			}
+			if !yield(Document{Name: hdr.Name, R: tr, Size: hdr.Size}, nil) {
+				return
+			}
+		}
+	}
+}
+
+func ZipDocuments(r io.ReaderAt, size int64, keep func(name string) bool) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		zr, err := zip.NewReader(r, size)
+		if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+			yield(Document{}, fmt.Errorf("read zip: %w", err))
+			return
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() || (keep != nil && !keep(f.Name)) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				yield(Document{Name: f.Name}, fmt.Errorf("read zip %s: %w", f.Name, err))
+				return
+			}
+			ok := yield(Document{Name: f.Name, R: rc, Size: int64(f.UncompressedSize64)}, nil)
+			_ = rc.Close()
+			if !ok {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package wordstat
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type archiveMember struct{ name, data string }
+
+var archiveMembers = []archiveMember{
+	{"docs/a.txt", "b a a"},
+	{"docs/b.md", "b c"},
+	{"skip.log", "x y z"},
+}
+
+func tarBytes(t *testing.T, members []archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, members []archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("docs/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveDocuments(t *testing.T) {
+	tarData, zipData := tarBytes(t, archiveMembers), zipBytes(t, archiveMembers)
+	if got := DetectArchive(tarData); got != "tar" {
+		t.Errorf("DetectArchive(tar) = %q", got)
+	}
+	if got := DetectArchive(zipData); got != "zip" {
+		t.Errorf("DetectArchive(zip) = %q", got)
+	}
+	if got := DetectArchive([]byte("plain text")); got != "" {
+		t.Errorf("DetectArchive(text) = %q", got)
+	}
+
+	keep := func(name string) bool { return !strings.HasSuffix(name, ".log") }
+	for format, docs := range map[string]func() iter.Seq2[Document, error]{
+		"tar": func() iter.Seq2[Document, error] { return TarDocuments(bytes.NewReader(tarData), keep) },
+		"zip": func() iter.Seq2[Document, error] {
+			return ZipDocuments(bytes.NewReader(zipData), int64(len(zipData)), keep)
+		},
+	} {
+		var got []archiveMember
+		for doc, err := range docs() {
+			if err != nil {
+				t.Fatalf("%s: error = %v", format, err)
+			}
+			data, err := io.ReadAll(doc.R)
+			if err != nil {
+				t.Fatalf("%s: read %s: %v", format, doc.Name, err)
+			}
+			if doc.Size != int64(len(data)) {
+				t.Errorf("%s: %s Size = %d, want %d", format, doc.Name, doc.Size, len(data))
+			}
+			got = append(got, archiveMember{doc.Name, string(data)})
+		}
+		if len(got) != 2 || got[0] != archiveMembers[0] || got[1] != archiveMembers[1] {
+			t.Errorf("%s: members = %q", format, got)
+		}
+	}
+
+	for _, err := range ZipDocuments(strings.NewReader("PK\x03\x04junk"), 8, nil) {
+		if err == nil {
+			t.Errorf("corrupt zip: no error")
+		}
+	}
+}
+
+func TestRunDocs_ByDocument(t *testing.T) {
+	data := tarBytes(t, archiveMembers[:2])
+	var text bytes.Buffer
+	opts := Options{SortBy: "count", ByDocument: true}
+	if err := RunDocsCtx(context.Background(), TarDocuments(bytes.NewReader(data), nil), &text, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "==> docs/a.txt <==\na 2\nb 1\n\n==> docs/b.md <==\nb 1\nc 1\n\n==> total <==\na 2\nb 2\nc 1\n"
+	if text.String() != want {
+		t.Fatalf("text report:\n%s\nwant:\n%s", text.String(), want)
+	}
+
+	var js bytes.Buffer
+	opts.Format, opts.K = "json", 1
+	if err := RunDocsCtx(context.Background(), TarDocuments(bytes.NewReader(data), nil), &js, opts); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"documents":[{"document":"docs/a.txt","words":[{"word":"a","count":2}]},{"document":"docs/b.md","words":[{"word":"b","count":1}]}],"total":[{"word":"a","count":2}]}` + "\n"
+	if js.String() != want {
+		t.Fatalf("json report:\n%s\nwant:\n%s", js.String(), want)
+	}
+
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, ByDocument: true, Approx: true}); err == nil {
+		t.Fatalf("ByDocument with Approx: no error")
+	}
+}
+
+func TestHTTPWordstat_Archive(t *testing.T) {
+	h := NewHTTPMuxWithConfig(HTTPConfig{MaxBodyBytes: 1 << 16})
+	post := func(body []byte, contentType, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var tgz bytes.Buffer
+	zw := gzip.NewWriter(&tgz)
+	_, _ = zw.Write(tarBytes(t, archiveMembers[:2]))
+	_ = zw.Close()
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{
+		"tar":    post(tarBytes(t, archiveMembers[:2]), "application/x-tar", ""),
+		"tar.gz": post(tgz.Bytes(), "application/x-compressed-tar", ""),
+		"zip":    post(zipBytes(t, archiveMembers[:2]), "application/zip", ""),
+	} {
+		if rr.Code != http.StatusOK || rr.Body.String() != "a 2\nb 2\nc 1\n" {
+			t.Fatalf("%s: status=%d body=%q", name, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := post(zipBytes(t, archiveMembers[:2]), "application/zip", "&k=1&bydoc=true&format=json")
+	want := `{"documents":[{"document":"docs/a.txt","words":[{"word":"a","count":2}]},{"document":"docs/b.md","words":[{"word":"b","count":1}]}],"total":[{"word":"a","count":2}]}` + "\n"
+	if rr.Code != http.StatusOK || rr.Body.String() != want {
+		t.Fatalf("bydoc: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	// members expanding past the limit together
+	big := strings.Repeat("a ", 20000)
+	rr = post(zipBytes(t, []archiveMember{{"1.txt", big}, {"2.txt", big}}), "application/zip", "")
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("zip bomb: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	rr = post([]byte("not a zip"), "application/zip", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad zip: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+}
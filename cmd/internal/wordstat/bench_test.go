@@ -3,6 +3,7 @@ package wordstat
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"math/rand"
 	"strings"
 	"testing"
@@ -60,6 +61,7 @@ func BenchmarkCountSequential(b *testing.B) {
 	input := GenerateBenchInput(k, n, minLen, maxLen, seed)
 	b.SetBytes(int64(len(input)))
 	b.ReportAllocs()
+	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		in := bufio.NewReader(strings.NewReader(input))
@@ -71,14 +73,39 @@ func BenchmarkCountSequential(b *testing.B) {
 	}
 }
 
+// исходный цикл ReadWord: строка на токен; сравнить allocs/op с BenchmarkCountSequential
+func BenchmarkCountReadWord(b *testing.B) {
+	input := GenerateBenchInput(k, n, minLen, maxLen, seed)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		in := bufio.NewReader(strings.NewReader(input))
+		m := map[string]int{}
+		for {
+			w, ok, err := ReadWord(in)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !ok {
+				break
+			}
+			m[w]++
+		}
+		sink = len(m)
+	}
+}
+
 func BenchmarkCountConcurrent(b *testing.B) {
 	input := GenerateBenchInput(k, n, minLen, maxLen, seed)
 	b.SetBytes(int64(len(input)))
 	b.ReportAllocs()
+	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		in := bufio.NewReader(strings.NewReader(input))
-		m, err := CountBufioConcurrent(context.Background(), in, 4, 1024)
+		m, err := CountBufioConcurrentBlocks(context.Background(), in, 4, 256<<10)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -90,6 +117,7 @@ func BenchmarkCountBuffered(b *testing.B) {
 	input := GenerateBenchInput(k, n, minLen, maxLen, seed)
 	b.SetBytes(int64(len(input)))
 	b.ReportAllocs()
+	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		m, err := CountReaderBuffered(context.Background(), strings.NewReader(input))
@@ -99,3 +127,74 @@ func BenchmarkCountBuffered(b *testing.B) {
 		sink = len(m)
 	}
 }
+
+func BenchmarkCountBytes(b *testing.B) {
+	input := []byte(GenerateBenchInput(k, n, minLen, maxLen, seed))
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m, err := CountBytes(context.Background(), input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = len(m)
+	}
+}
+
+func BenchmarkCountBytesParallel(b *testing.B) {
+	input := []byte(GenerateBenchInput(k, n, minLen, maxLen, seed))
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				m, err := CountBytesParallel(context.Background(), input, workers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				sink = len(m)
+			}
+		})
+	}
+}
+
+// словарь size слов с частотами по Ципфу
+func benchCounts(size int) map[string]int {
+	rng := rand.New(rand.NewSource(seed))
+	counts := make(map[string]int, size)
+	for i := 0; i < size; i++ {
+		counts[fmt.Sprintf("w%08x", rng.Uint32())] = 1 + size/(1+rng.Intn(size))
+	}
+	return counts
+}
+
+func BenchmarkTopK(b *testing.B) {
+	for _, size := range []int{10_000, 100_000, 1_000_000} {
+		counts := benchCounts(size)
+		for _, sortBy := range []string{"count", "word"} {
+			opts := Options{SortBy: sortBy, K: 10, Min: 1}
+
+			b.Run(fmt.Sprintf("vocab=%d/sort=%s/heap", size, sortBy), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					entries := TopK(counts, opts)
+					SortEntries(entries, opts)
+					sink = len(entries)
+				}
+			})
+			b.Run(fmt.Sprintf("vocab=%d/sort=%s/full", size, sortBy), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					entries := FilterMin(BuildEntries(counts), opts.Min)
+					SortEntries(entries, opts)
+					sink = len(entries[:opts.K])
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package wordstat
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// NewCharTokenizer: токены - руны (n <= 1) или окна по n рун, окна не переходят через
+// пропущенную руну (пробелы, управляющие, BOM, битый UTF-8, с lettersOnly - не буквы).
+func NewCharTokenizer(lettersOnly bool, n int) Tokenizer {
+	if n < 1 {
+		n = 1
+	}
+	return charTokenizer{lettersOnly: lettersOnly, n: n}
+}
+
+type charTokenizer struct {
+	lettersOnly bool
+	n           int
+}
+
+func (t charTokenizer) counted(r rune) bool {
+	if r == utf8.RuneError || r == '\ufeff' {
+		return false
+	}
+	if t.lettersOnly {
+		return unicode.IsLetter(r)
+	}
+	return !unicode.IsSpace(r) && !unicode.IsControl(r)
+}
+
+func (t charTokenizer) Split(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+scan:
+	for start < len(data) {
+		if !atEOF && !utf8.FullRune(data[start:]) {
+			return start, nil, nil
+		}
+		r, w := utf8.DecodeRune(data[start:])
+		if !t.counted(r) {
+			start += w
+			continue
+		}
+
+		end := start
+		for k := 0; k < t.n; k++ {
+			if end >= len(data) {
+				if !atEOF {
+					return start, nil, nil
+				}
+				// последний отрезок короче окна
+				return len(data), nil, nil
+			}
+			if !atEOF && !utf8.FullRune(data[end:]) {
+				return start, nil, nil
+			}
+			r, w := utf8.DecodeRune(data[end:])
+			if !t.counted(r) {
+				// отрезок короче окна - пропускаем
+				start = end + w
+				continue scan
+			}
+			end += w
+		}
+
+		// окна перекрываются: сдвиг на одну руну
+		_, w = utf8.DecodeRune(data[start:])
+		return start + w, data[start:end], nil
+	}
+	return start, nil, nil
+}
+
+func (charTokenizer) AppendNormalized(dst, tok []byte) []byte {
+	return AppendNormalizedWord(dst, tok)
+}
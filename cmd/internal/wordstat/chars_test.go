@@ -0,0 +1,85 @@
+package wordstat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRun_CharUnit_AllEngines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{
+		{
+			name:  "runes",
+			input: "Абба, ab!",
+			opts:  Options{Unit: "char"},
+			want:  "а 2\nб 2\n! 1\n, 1\na 1\nb 1\n",
+		},
+		{
+			name:  "letters only",
+			input: "Абба, ab!",
+			opts:  Options{Unit: "char", LettersOnly: true},
+			want:  "а 2\nб 2\na 1\nb 1\n",
+		},
+		{
+			name:  "bigrams stay inside words",
+			input: "THE then\the",
+			opts:  Options{Unit: "char-ngram", LettersOnly: true},
+			want:  "he 3\nth 2\nen 1\n",
+		},
+		{
+			name:  "trigrams",
+			input: "abcd bcd",
+			opts:  Options{Unit: "char-ngram", Ngram: 3},
+			want:  "bcd 2\nabc 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Options{{Workers: 1}, {Workers: 2}, {Buffered: true}} {
+			opts := tt.opts
+			opts.SortBy = "count"
+			opts.Workers = engine.Workers
+			opts.Buffered = engine.Buffered
+
+			var out strings.Builder
+			if err := Run(strings.NewReader(tt.input), &out, opts); err != nil {
+				t.Fatalf("%s: Run(%+v) error = %v", tt.name, opts, err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("%s: Run(%+v) got:\n%q\nwant:\n%q", tt.name, opts, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestValidateOptions_Unit(t *testing.T) {
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Unit: "byte"}); err == nil {
+		t.Fatalf("expected error for unknown unit")
+	}
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Unit: "char", Ngram: 2}); err == nil {
+		t.Fatalf("expected error for -unit=char with -ngram")
+	}
+}
+
+func TestHTTPWordstat_CharNgram(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&unit=char-ngram&ngram=2&letters=true", strings.NewReader("aaa, a1a"))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	want := "aa 2\n"
+	if rr.Body.String() != want {
+		t.Fatalf("got=%q want=%q", rr.Body.String(), want)
+	}
+}
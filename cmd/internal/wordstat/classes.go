@@ -0,0 +1,280 @@
+package wordstat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// классы токенов (Entry.Class) в порядке отчёта
+const (
+	ClassWord    = "word"
+	ClassNumber  = "number"
+	ClassURL     = "url"
+	ClassEmail   = "email"
+	ClassHashtag = "hashtag"
+	ClassMention = "mention"
+	ClassEmoji   = "emoji"
+)
+
+var classOrder = []string{ClassWord, ClassNumber, ClassURL, ClassEmail, ClassHashtag, ClassMention, ClassEmoji}
+
+func classRank(class string) int {
+	for i, c := range classOrder {
+		if c == class {
+			return i
+		}
+	}
+	return len(classOrder)
+}
+
+var (
+	urlPrefixRe = regexp.MustCompile(`^(?i:(?:https?|ftp)://|www\.)\S`)
+	emailRe     = regexp.MustCompile(`^[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)*\.\p{L}{2,}$`)
+)
+
+// срезается с конца URL и email: "(see x.org/a)."
+const trailingPunct = `.,;:!?)]}>"'`
+
+func ClassifyToken(tok string) string {
+	switch {
+	case urlPrefixRe.MatchString(tok):
+		return ClassURL
+	case emailRe.MatchString(tok):
+		return ClassEmail
+	case len(tok) > 1 && tok[0] == '#':
+		return ClassHashtag
+	case len(tok) > 1 && tok[0] == '@':
+		return ClassMention
+	}
+	r, _ := utf8.DecodeRuneInString(tok)
+	if isEmoji(r) {
+		return ClassEmoji
+	}
+	if isNumber(tok) {
+		return ClassNumber
+	}
+	return ClassWord
+}
+
+func isNumber(tok string) bool {
+	digits := false
+	for _, r := range tok {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case r == '.' || r == ',':
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case 0x1F000 <= r && r <= 0x1FAFF:
+		return true
+	case 0x2600 <= r && r <= 0x27BF:
+		return true
+	case 0x2B00 <= r && r <= 0x2BFF, r == 0x2122, r == 0x2139, r == 0x231A, r == 0x231B, r == 0x23F0:
+		return true
+	default:
+		return false
+	}
+}
+
+// ZWJ, селекторы вариантов, keycap и tag-символы продолжают emoji
+func isEmojiExtender(r rune) bool {
+	return r == 0x200D || r == 0xFE0F || r == 0xFE0E || r == 0x20E3 || (0xE0020 <= r && r <= 0xE007F)
+}
+
+// TypedTokenizer: URL, email, #хэштеги, @упоминания и emoji целиком,
+// остальное - как ScanUnicodeWords.
+var TypedTokenizer Tokenizer = splitTokenizer{split: ScanTypedTokens, spaceAligned: true}
+
+func ScanTypedTokens(data []byte, atEOF bool) (int, []byte, error) {
+	p := 0
+	for {
+		// классы не переходят через пробел
+		for p < len(data) {
+			if !atEOF && !utf8.FullRune(data[p:]) {
+				return p, nil, nil
+			}
+			r, w := utf8.DecodeRune(data[p:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			p += w
+		}
+		end := p
+		for end < len(data) {
+			if !atEOF && !utf8.FullRune(data[end:]) {
+				return p, nil, nil
+			}
+			r, w := utf8.DecodeRune(data[end:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			end += w
+		}
+		if p >= len(data) || (end == len(data) && !atEOF) {
+			return p, nil, nil
+		}
+
+		if start, stop, ok := nextTyped(data[:end], p); ok {
+			return stop, data[start:stop], nil
+		}
+		p = end
+	}
+}
+
+func nextTyped(chunk []byte, p int) (start, end int, ok bool) {
+	for p < len(chunk) {
+		rest := chunk[p:]
+		if urlPrefixRe.Match(rest) {
+			return p, p + len(trimTrailing(rest)), true
+		}
+		if t := trimTrailing(rest); emailRe.Match(t) {
+			return p, p + len(t), true
+		}
+
+		r, w := utf8.DecodeRune(rest)
+		switch {
+		case (r == '#' || r == '@') && w < len(rest):
+			next, _ := utf8.DecodeRune(rest[w:])
+			if isWordRune(next) {
+				n := w
+				for n < len(rest) {
+					r, rw := utf8.DecodeRune(rest[n:])
+					if !isWordRune(r) {
+						break
+					}
+					n += rw
+				}
+				return p, p + n, true
+			}
+		case isEmoji(r):
+			n := w
+			for n < len(rest) {
+				r, rw := utf8.DecodeRune(rest[n:])
+				// тон кожи и regional indicator - сами по себе emoji
+				if !isEmojiExtender(r) && !(isEmoji(r) && (isEmojiExtender(lastRune(rest[:n])) || isEmojiModifier(r) || isRegionalPair(rest[:n], r))) {
+					break
+				}
+				n += rw
+			}
+			return p, p + n, true
+		case isWordRune(r):
+			adv, tok, _ := ScanUnicodeWords(rest, true)
+			if tok != nil {
+				start := p + adv - len(tok)
+				return start, p + adv, true
+			}
+		}
+		p += w
+	}
+	return 0, 0, false
+}
+
+func trimTrailing(b []byte) []byte {
+	for len(b) > 0 && strings.IndexByte(trailingPunct, b[len(b)-1]) >= 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func lastRune(b []byte) rune {
+	r, _ := utf8.DecodeLastRune(b)
+	return r
+}
+
+func isEmojiModifier(r rune) bool { return 0x1F3FB <= r && r <= 0x1F3FF }
+
+func isRegionalPair(seq []byte, r rune) bool {
+	isRI := func(r rune) bool { return 0x1F1E6 <= r && r <= 0x1F1FF }
+	return isRI(r) && utf8.RuneCount(seq) == 1 && isRI(lastRune(seq))
+}
+
+// ClassFilter: с Include остаются только эти классы, Exclude отбрасываются всегда.
+type ClassFilter struct {
+	Include map[string]bool
+	Exclude map[string]bool
+}
+
+func ParseClassFilter(s string) (ClassFilter, error) {
+	var f ClassFilter
+	if s == "" {
+		return f, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		set := &f.Include
+		if name, ok := strings.CutPrefix(item, "-"); ok {
+			item, set = name, &f.Exclude
+		}
+		if classRank(item) == len(classOrder) {
+			return ClassFilter{}, fmt.Errorf("unknown token class %q (use %s)", item, strings.Join(classOrder, "|"))
+		}
+		if *set == nil {
+			*set = make(map[string]bool)
+		}
+		(*set)[item] = true
+	}
+	return f, nil
+}
+
+func (f ClassFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+func (f ClassFilter) Keep(class string) bool {
+	if f.Exclude[class] {
+		return false
+	}
+	return len(f.Include) == 0 || f.Include[class]
+}
+
+// n-грамма остаётся, только если проходят все её токены, и класса не получает
+func classifyEntries(entries []Entry, f ClassFilter) []Entry {
+	dst := entries[:0]
+	for _, e := range entries {
+		if e.Tokens != nil {
+			keep := true
+			for _, tok := range e.Tokens {
+				keep = keep && f.Keep(ClassifyToken(tok))
+			}
+			if keep {
+				dst = append(dst, e)
+			}
+			continue
+		}
+		w := e.Word
+		if e.Form != "" {
+			w = e.Form // основа "#tag" или URL может не классифицироваться
+		}
+		e.Class = ClassifyToken(w)
+		if f.Keep(e.Class) {
+			dst = append(dst, e)
+		}
+	}
+	return dst
+}
+
+// entries отсортированы с ByClass
+func limitPerClass(entries []Entry, k int) []Entry {
+	dst := entries[:0]
+	n, class := 0, ""
+	for i, e := range entries {
+		if i == 0 || e.Class != class {
+			n, class = 0, e.Class
+		}
+		if n < k {
+			dst = append(dst, e)
+		}
+		n++
+	}
+	return dst
+}
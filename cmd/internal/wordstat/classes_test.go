@@ -0,0 +1,167 @@
+package wordstat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanTypedTokens(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"see https://go.dev/doc, ok", []string{"see", "https://go.dev/doc", "ok"}},
+		{"(www.example.org/a).", []string{"www.example.org/a"}},
+		{"mail: Ivan.P@mail.ru!", []string{"mail", "Ivan.P@mail.ru"}},
+		{"#go1 and @rob_pike, #", []string{"#go1", "and", "@rob_pike"}},
+		{"it's 1,024.5 bytes", []string{"it's", "1,024.5", "bytes"}},
+		{"ура🎉🎉 👍🏽", []string{"ура", "🎉", "🎉", "👍🏽"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		data := []byte(tt.input)
+		for len(data) > 0 {
+			adv, tok, err := ScanTypedTokens(data, true)
+			if err != nil {
+				t.Fatalf("%q: error = %v", tt.input, err)
+			}
+			if adv == 0 {
+				break
+			}
+			if tok != nil {
+				got = append(got, string(tok))
+			}
+			data = data[adv:]
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%q: got=%q want=%q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyToken(t *testing.T) {
+	tests := map[string]string{
+		"hello":        ClassWord,
+		"1,024":        ClassNumber,
+		"3.14":         ClassNumber,
+		"http://x.org": ClassURL,
+		"www.x.org":    ClassURL,
+		"a.b@mail.ru":  ClassEmail,
+		"#golang":      ClassHashtag,
+		"@gopher":      ClassMention,
+		"🎉":            ClassEmoji,
+		"x86":          ClassWord,
+		"hello@world":  ClassWord,
+	}
+	for tok, want := range tests {
+		if got := ClassifyToken(tok); got != want {
+			t.Fatalf("ClassifyToken(%q)=%q want=%q", tok, got, want)
+		}
+	}
+}
+
+func TestParseClassFilter(t *testing.T) {
+	f, err := ParseClassFilter("word, hashtag,-url")
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	for class, want := range map[string]bool{"word": true, "hashtag": true, "url": false, "emoji": false} {
+		if got := f.Keep(class); got != want {
+			t.Fatalf("Keep(%q)=%v want=%v", class, got, want)
+		}
+	}
+
+	f, _ = ParseClassFilter("-url,-email")
+	if !f.Keep("word") || f.Keep("email") {
+		t.Fatalf("exclude-only filter: %+v", f)
+	}
+
+	if _, err := ParseClassFilter("word,phone"); err == nil {
+		t.Fatalf("expected error for unknown class")
+	}
+}
+
+const chatInput = "Go #go https://go.dev go! #GO @rob 🎉 42 #go rob@go.dev\n"
+
+func TestRun_Classes_AllEngines(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "keep hashtags and mentions",
+			opts: Options{Classes: ClassFilter{Include: map[string]bool{"hashtag": true, "mention": true}}},
+			want: "#go 3 hashtag\n@rob 1 mention\n",
+		},
+		{
+			name: "drop urls and emails",
+			opts: Options{Classes: ClassFilter{Exclude: map[string]bool{"url": true, "email": true, "hashtag": true}}},
+			want: "go 2 word\n42 1 number\n@rob 1 mention\n🎉 1 emoji\n",
+		},
+		{
+			name: "top 1 per class",
+			opts: Options{ByClass: true, K: 1},
+			want: "go 2 word\n42 1 number\nhttps://go.dev 1 url\nrob@go.dev 1 email\n#go 3 hashtag\n@rob 1 mention\n🎉 1 emoji\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Options{{Workers: 1}, {Workers: 3}, {Buffered: true}} {
+			opts := tt.opts
+			opts.SortBy = "count"
+			opts.Workers = engine.Workers
+			opts.Buffered = engine.Buffered
+
+			var out strings.Builder
+			if err := Run(strings.NewReader(chatInput), &out, opts); err != nil {
+				t.Fatalf("%s: Run(%+v) error = %v", tt.name, opts, err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("%s: Run(%+v) got:\n%q\nwant:\n%q", tt.name, opts, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestValidateOptions_ClassesWithCharUnit(t *testing.T) {
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Unit: "char", ByClass: true}); err == nil {
+		t.Fatalf("expected error for -by-class with -unit=char")
+	}
+}
+
+func TestHTTPWordstat_Classes(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&format=json&classes=hashtag,url", strings.NewReader(chatInput))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	var got []Entry
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("bad json: %v body=%q", err, rr.Body.String())
+	}
+	want := []Entry{
+		{Word: "#go", Count: 3, Class: "hashtag"},
+		{Word: "https://go.dev", Count: 1, Class: "url"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%+v want=%+v", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/wordstat?classes=phone", strings.NewReader("x"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("classes=phone: status=%d want=%d", rr.Code, http.StatusBadRequest)
+	}
+}
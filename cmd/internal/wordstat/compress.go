@@ -0,0 +1,50 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// из заголовков zlib - только непечатные (78 01, 78 9c, 78 da), чтобы текст с "x^" не приняли за сжатый
+func DetectCompression(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case len(sample) >= 4 && bytes.HasPrefix(sample, []byte("BZh")) && sample[3] >= '1' && sample[3] <= '9':
+		return "bzip2"
+	case len(sample) >= 2 && sample[0] == 0x78 && (sample[1] == 0x01 || sample[1] == 0x9c || sample[1] == 0xda):
+		return "zlib"
+	}
+	return ""
+}
+
+// склеенные gzip-члены (ротация логов) читаются как один поток
+func NewDecompressingReader(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	sample, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf("detect compression: %w", err)
+	}
+	format := DetectCompression(sample)
+
+	var dr io.Reader
+	switch format {
+	case "":
+		return br, "", nil
+	case "gzip":
+		dr, err = gzip.NewReader(br)
+	case "bzip2":
+		dr = bzip2.NewReader(br)
+	case "zlib":
+		dr, err = zlib.NewReader(br)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", format, err)
+	}
+	return dr, format, nil
+}
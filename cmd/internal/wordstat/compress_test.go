@@ -0,0 +1,112 @@
+package wordstat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// "b a a b c\n" в bzip2 (в stdlib нет writer)
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xeb, 0x9c, 0xb0, 0x7c, 0x00, 0x00,
+	0x03, 0xd1, 0x00, 0x00, 0x10, 0x40, 0x00, 0x38, 0x00, 0x20, 0x00, 0x21, 0x21, 0x3d, 0x41, 0x9a,
+	0x05, 0x4a, 0x8d, 0x3c, 0x5d, 0xc9, 0x14, 0xe1, 0x42, 0x43, 0xae, 0x72, 0xc1, 0xf0,
+}
+
+func gzipBytes(t *testing.T, parts ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, p := range parts { // one gzip member per part
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestNewDecompressingReader(t *testing.T) {
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	_, _ = zw.Write([]byte("b a a b c\n"))
+	_ = zw.Close()
+
+	tests := map[string]struct {
+		in     []byte
+		format string
+	}{
+		"plain":       {[]byte("b a a b c\n"), ""},
+		"x^ text":     {[]byte("x^2 is not zlib"), ""},
+		"gzip":        {gzipBytes(t, "b a a b c\n"), "gzip"},
+		"gzip member": {gzipBytes(t, "b a a ", "b c\n"), "gzip"},
+		"bzip2":       {bzip2Sample, "bzip2"},
+		"zlib":        {zbuf.Bytes(), "zlib"},
+		"empty":       {nil, ""},
+	}
+	for name, tt := range tests {
+		r, format, err := NewDecompressingReader(bytes.NewReader(tt.in))
+		if err != nil {
+			t.Fatalf("%s: NewDecompressingReader error = %v", name, err)
+		}
+		if format != tt.format {
+			t.Errorf("%s: format = %q, want %q", name, format, tt.format)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: read error = %v", name, err)
+		}
+		if tt.format != "" && string(got) != "b a a b c\n" {
+			t.Errorf("%s: decompressed = %q", name, got)
+		}
+		if tt.format == "" && !bytes.Equal(got, tt.in) {
+			t.Errorf("%s: got %q, want the input unchanged", name, got)
+		}
+	}
+
+	if _, _, err := NewDecompressingReader(bytes.NewReader([]byte{0x1f, 0x8b, 0x00})); err == nil {
+		t.Errorf("truncated gzip header: no error")
+	}
+}
+
+func TestHTTPWordstat_Gzip(t *testing.T) {
+	h := NewHTTPMuxWithConfig(HTTPConfig{MaxBodyBytes: 1000})
+	post := func(body []byte, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&engine=auto", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(gzipBytes(t, "b a a b c"), "gzip")
+	if rr.Code != http.StatusOK || rr.Body.String() != "a 2\nb 2\nc 1\n" {
+		t.Fatalf("gzip: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("X-Wordstat-Engine"); got == "buffered" {
+		t.Fatalf("gzip: engine chosen by the compressed size")
+	}
+
+	// 2000 bytes decompressed from a few dozen: over the limit
+	bomb := gzipBytes(t, strings.Repeat("a ", 1000))
+	if len(bomb) >= 1000 {
+		t.Fatalf("bomb is %d bytes compressed", len(bomb))
+	}
+	if rr := post(bomb, "gzip"); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("bomb: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	if rr := post([]byte("not gzip"), "gzip"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad gzip: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	if rr := post([]byte("a"), "br"); rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("br: status=%d body=%q", rr.Code, rr.Body.String())
+	}
+}
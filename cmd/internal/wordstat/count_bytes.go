@@ -1,83 +1,137 @@
 package wordstat
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"strings"
-	"unsafe"
+	"iter"
+	"sync"
 )
 
 func CountReaderBuffered(ctx context.Context, r io.Reader) (map[string]int, error) {
+	return counted(func(c Counter) error { return countReaderBuffered(ctx, r, 1, WhitespaceTokenizer, 1, c) })
+}
+
+func countReaderBuffered(ctx context.Context, r io.Reader, workers int, t Tokenizer, ngram int, dst Counter) error {
 	data, err := io.ReadAll(ctxReader{ctx: ctx, r: r})
 	if err != nil {
-		return nil, fmt.Errorf("read all: %w", err)
+		return fmt.Errorf("read all: %w", err)
 	}
-	return CountBytes(ctx, data)
+	return countBytesParallel(ctx, data, workers, t, ngram, dst)
 }
 
 func CountBytes(ctx context.Context, data []byte) (map[string]int, error) {
-	counts := make(map[string]int)
+	return counted(func(c Counter) error { return countBytes(ctx, data, WhitespaceTokenizer, 1, c) })
+}
 
-	i := 0
-	for i < len(data) {
-		if i&0xFFFF == 0 {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
-			}
+func CountBytesParallel(ctx context.Context, data []byte, workers int) (map[string]int, error) {
+	return counted(func(c Counter) error { return countBytesParallel(ctx, data, workers, WhitespaceTokenizer, 1, c) })
+}
+
+func countBytes(ctx context.Context, data []byte, t Tokenizer, ngram int, dst Counter) error {
+	return countChunk(ctx, data, nil, t, ngram, adderFor(dst))
+}
+
+var minChunk = 256 << 10
+
+// куски режутся по пробелам; токенизаторы, которым это не подходит, идут последовательно
+func countBytesParallel(ctx context.Context, data []byte, workers int, t Tokenizer, ngram int, dst Counter) error {
+	workers = min(workers, len(data)/minChunk)
+	if workers <= 1 || !spaceAligned(t) {
+		return countBytes(ctx, data, t, ngram, dst)
+	}
+
+	cuts := make([]int, workers+1)
+	for i := 1; i < workers; i++ {
+		c := max(i*len(data)/workers, cuts[i-1])
+		for c < len(data) && !isSpace(data[c]) {
+			c++
 		}
-		for i < len(data) && isSpace(data[i]) {
-			i++
+		cuts[i] = min(c+1, len(data))
+	}
+	cuts[workers] = len(data)
+
+	merge := mergerFor(dst)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts := NewMapCounter()
+			after := func(yield func([]byte) bool) { yield(data[cuts[i+1]:]) }
+			if errs[i] = countChunk(ctx, data[cuts[i]:cuts[i+1]], after, t, ngram, counts); errs[i] == nil {
+				errs[i] = merge(counts)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// n-грамма принадлежит куску с её первым токеном: до ngram-1 токенов дочитываются из after
+func countChunk(ctx context.Context, chunk []byte, after iter.Seq[[]byte], t Tokenizer, ngram int, counts keyAdder) error {
+	c := chunkCounter{ctx: ctx, t: t, win: newNgramWindow(ngram), counts: counts}
+	if _, err := c.feed(chunk, -1); err != nil || ngram <= 1 || after == nil {
+		return err
+	}
+	need := ngram - 1
+	for data := range after {
+		pushed, err := c.feed(data, need)
+		if err != nil {
+			return err
 		}
-		if i >= len(data) {
+		if need -= pushed; need == 0 {
 			break
 		}
-		start := i
-		for i < len(data) && !isSpace(data[i]) {
-			i++
-		}
-		end := i
+	}
+	return nil
+}
 
-		if end-start >= 3 && data[start] == 0xEF && data[start+1] == 0xBB && data[start+2] == 0xBF {
-			start += 3
-			if start >= end {
-				continue
-			}
-		}
+type chunkCounter struct {
+	ctx    context.Context
+	t      Tokenizer
+	win    *ngramWindow
+	counts keyAdder
+	norm   []byte
+	n      int
 
-		ascii := true
-		for j := start; j < end; j++ {
-			b := data[j]
-			if b >= 0x80 {
-				ascii = false
-				break
-			}
-			if 'A' <= b && b <= 'Z' {
-				data[j] = b + ('a' - 'A')
+	headN int // сколько первых токенов копировать в head
+	head  [][]byte
+}
+
+// с limit >= 0 останавливается после limit токенов
+func (c *chunkCounter) feed(data []byte, limit int) (pushed int, err error) {
+	for len(data) > 0 && pushed != limit {
+		if c.n&0xFFF == 0 {
+			select {
+			case <-c.ctx.Done():
+				return pushed, c.ctx.Err()
+			default:
 			}
 		}
-
-		if ascii {
-			// zero-copy string for LOOKUP ONLY
-			tok := unsafe.String(&data[start], end-start)
-
-			if c, ok := counts[tok]; ok {
-				counts[tok] = c + 1
-			} else {
-				// Новый ключ кладём как безопасную копию
-				key := string(data[start:end])
-				counts[key] = 1
+		c.n++
+		adv, tok, err := c.t.Split(data, true)
+		if err != nil && !errors.Is(err, bufio.ErrFinalToken) {
+			return pushed, fmt.Errorf("split words: %w", err)
+		}
+		if tok != nil {
+			c.norm = c.t.AppendNormalized(c.norm[:0], tok)
+			if len(c.norm) > 0 {
+				pushed++
+				if len(c.head) < c.headN {
+					c.head = append(c.head, bytes.Clone(c.norm))
+				}
 			}
-			continue
+			c.counts.add(c.win.push(c.norm))
 		}
-
-		s := string(data[start:end])
-		s = strings.TrimPrefix(s, "\ufeff")
-		s = strings.ToLower(s)
-		counts[s]++
+		if err != nil || adv <= 0 {
+			break
+		}
+		data = data[adv:]
 	}
-
-	return counts, nil
+	return pushed, nil
 }
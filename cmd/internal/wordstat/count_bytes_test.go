@@ -0,0 +1,111 @@
+package wordstat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func randomText(rng *rand.Rand, n int) string {
+	parts := []string{"a", "Bb", "ccc", "д", "ёлка", "it's", "1,024", "#go", "@rob", "x.org", "🎉", ",", "--", "\ufeff"}
+	spaces := []string{" ", "  ", "\n", "\r\n", "\t", "\u00a0"}
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString(parts[rng.Intn(len(parts))])
+		if rng.Intn(4) > 0 {
+			sb.WriteString(spaces[rng.Intn(len(spaces))])
+		}
+	}
+	return sb.String()
+}
+
+func TestCountBytesParallel_MatchesSequential(t *testing.T) {
+	defer func(n int) { minChunk = n }(minChunk)
+	minChunk = 1
+
+	tokenizers := map[string]Tokenizer{
+		"whitespace": WhitespaceTokenizer,
+		"unicode":    UnicodeTokenizer,
+		"typed":      TypedTokenizer,
+		"char3":      NewCharTokenizer(false, 3),
+		"fold":       WithNormalizer(UnicodeTokenizer, Normalizer{CaseFold: "fold", MergeYo: true}),
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20; i++ {
+		data := []byte(randomText(rng, rng.Intn(200)))
+		for name, tok := range tokenizers {
+			for ngram := 1; ngram <= 3; ngram++ {
+				want, err := counted(func(c Counter) error { return countBytes(ctx, data, tok, ngram, c) })
+				if err != nil {
+					t.Fatalf("countBytes error = %v", err)
+				}
+				for workers := 2; workers <= 9; workers += 7 {
+					got, err := counted(func(c Counter) error { return countBytesParallel(ctx, data, workers, tok, ngram, c) })
+					if err != nil {
+						t.Fatalf("countBytesParallel error = %v", err)
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s ngram=%d workers=%d input=%q:\ngot=%q\nwant=%q", name, ngram, workers, data, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCountBytesParallel_Canceled(t *testing.T) {
+	defer func(n int) { minChunk = n }(minChunk)
+	minChunk = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := CountBytesParallel(ctx, []byte("a b c d e f"), 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v want context.Canceled", err)
+	}
+}
+
+func TestRunDocs_Data(t *testing.T) {
+	data := []byte("Мир a\nмир b A")
+	orig := string(data)
+	docs := func(yield func(Document, error) bool) {
+		_ = yield(Document{Name: "mapped", Data: data}, nil) &&
+			yield(Document{Name: "read", R: strings.NewReader("b")}, nil)
+	}
+
+	for _, opts := range []Options{
+		{SortBy: "count", Workers: 2},
+		{SortBy: "count", Pattern: `\pL+`},
+	} {
+		var out strings.Builder
+		if err := RunDocsCtx(context.Background(), docs, &out, opts); err != nil {
+			t.Fatalf("RunDocsCtx(%+v) error = %v", opts, err)
+		}
+		want := "a 2\nb 2\nмир 2\n"
+		if out.String() != want {
+			t.Fatalf("RunDocsCtx(%+v) got:\n%q\nwant:\n%q", opts, out.String(), want)
+		}
+	}
+	if string(data) != orig {
+		t.Fatalf("Data was modified: %q", data)
+	}
+
+	// MapDocuments reads Data through the wrapped reader
+	twice := MapDocuments(docs, func(r io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(r)
+		return strings.NewReader(strings.Repeat(string(b)+" ", 2)), err
+	})
+	var out strings.Builder
+	if err := RunDocsCtx(context.Background(), twice, &out, Options{SortBy: "count", Min: 4}); err != nil {
+		t.Fatalf("RunDocsCtx() error = %v", err)
+	}
+	if want := "a 4\nb 4\nмир 4\n"; out.String() != want {
+		t.Fatalf("MapDocuments: got=%q want=%q", out.String(), want)
+	}
+}
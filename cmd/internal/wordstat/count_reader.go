@@ -3,24 +3,140 @@ package wordstat
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 )
 
 func CountBufio(ctx context.Context, in *bufio.Reader) (map[string]int, error) {
-	counts := make(map[string]int)
+	return counted(func(c Counter) error { return countBufio(ctx, in, WhitespaceTokenizer, 1, c) })
+}
+
+func countBufio(ctx context.Context, in *bufio.Reader, t Tokenizer, ngram int, dst Counter) error {
+	return scanKeys(ctx, in, t, ngram, adderFor(dst))
+}
+
+// пустые ключи игнорируются
+type keyAdder interface {
+	add(key []byte)
+}
+
+func scanKeys(ctx context.Context, in *bufio.Reader, t Tokenizer, ngram int, c keyAdder) error {
+	sc := newWindowScanner(in, t)
+	win := newNgramWindow(ngram)
+	var norm []byte
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return ctx.Err()
 		default:
 		}
-		s, ok, err := ReadWord(in)
+		if !sc.Scan() {
+			break
+		}
+		norm = t.AppendNormalized(norm[:0], sc.Bytes())
+		c.add(win.push(norm))
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("scan words: %w", err)
+	}
+	return nil
+}
+
+// windowScanner режет токены прямо в буфере bufio.Reader (Peek + Discard), без копии,
+// которую делает bufio.Scanner. Длинный токен читается через bufio.Reader побольше.
+type windowScanner struct {
+	in      *bufio.Reader
+	t       Tokenizer
+	tok     []byte
+	readErr error
+	err     error
+	done    bool
+	empties int
+}
+
+func newWindowScanner(in *bufio.Reader, t Tokenizer) *windowScanner {
+	return &windowScanner{in: in, t: t}
+}
+
+// токен указывает в буфер и живёт до следующего Scan
+func (s *windowScanner) Bytes() []byte { return s.tok }
+
+func (s *windowScanner) Err() error { return s.err }
+
+func (s *windowScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	more := s.in.Buffered() == 0
+	for {
+		if more && s.readErr == nil {
+			if _, err := s.in.Peek(s.in.Size()); err != nil {
+				s.readErr = err
+			}
+		}
+		data, _ := s.in.Peek(s.in.Buffered())
+		atEOF := s.readErr != nil
+
+		adv, tok, err := splitAtEOF(s.t, data, atEOF)
 		if err != nil {
-			return nil, err
+			s.done = true
+			if errors.Is(err, bufio.ErrFinalToken) {
+				s.tok = tok
+				return tok != nil
+			}
+			s.err = err
+			return false
+		}
+		if adv < 0 || adv > len(data) {
+			s.done, s.err = true, bufio.ErrNegativeAdvance
+			if adv > 0 {
+				s.err = bufio.ErrAdvanceTooFar
+			}
+			return false
+		}
+		_, _ = s.in.Discard(adv)
+
+		if tok != nil {
+			if adv == 0 {
+				if s.empties++; s.empties > 100 {
+					s.done, s.err = true, io.ErrNoProgress
+					return false
+				}
+			} else {
+				s.empties = 0
+			}
+			s.tok = tok
+			return true
+		}
+		if adv > 0 {
+			continue
+		}
+
+		if atEOF {
+			s.done = true
+			if s.readErr != io.EOF {
+				s.err = s.readErr
+			}
+			return false
 		}
-		if !ok {
+		if len(data) == s.in.Size() {
+			s.in = bufio.NewReaderSize(s.in, 2*s.in.Size())
+		}
+		more = true
+	}
+}
+
+// на EOF bufio.Scanner останавливается на advance без токена, CountBytes - нет
+func splitAtEOF(t Tokenizer, data []byte, atEOF bool) (int, []byte, error) {
+	adv, tok, err := t.Split(data, atEOF)
+	for atEOF && tok == nil && err == nil && adv > 0 && adv < len(data) {
+		var n int
+		n, tok, err = t.Split(data[adv:], atEOF)
+		if n <= 0 {
 			break
 		}
-		counts[s]++
+		adv += n
 	}
-	return counts, nil
+	return adv, tok, err
 }
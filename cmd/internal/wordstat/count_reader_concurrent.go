@@ -3,131 +3,178 @@ package wordstat
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
 	"sync"
 )
 
-func CountBufioConcurrent(ctx context.Context, in *bufio.Reader, workers int, batchSize int) (map[string]int, error) {
-	if workers <= 1 {
-		return CountBufio(ctx, in)
-	}
+// размер блока по умолчанию (1 MB)
+const concurrentBlockSize = 1 << 20
 
-	if batchSize <= 0 {
-		batchSize = 1024
-	}
+// CountBufioConcurrentBlocks считает как CountBufio в workers горутинах; вход режется
+// по пробелам на блоки не меньше blockSize байт (0 = 1 MB).
+func CountBufioConcurrentBlocks(ctx context.Context, in *bufio.Reader, workers int, blockSize int) (map[string]int, error) {
+	return counted(func(c Counter) error {
+		return countBufioConcurrent(ctx, in, workers, blockSize, WhitespaceTokenizer, 1, c)
+	})
+}
+
+type block struct {
+	idx  int
+	data []byte
+}
+
+// первые и последние ngram-1 токенов блока - для n-грамм на границах блоков
+type blockEdges struct {
+	head, tail [][]byte
+}
 
-	type shard struct {
-		ch chan []string
-		m  map[string]int
+// countBufioConcurrent: эта горутина только читает блоки, воркеры режут и считают их
+// в свои счётчики и в конце сливают в dst.
+func countBufioConcurrent(ctx context.Context, in *bufio.Reader, workers int, blockSize int, t Tokenizer, ngram int, dst Counter) error {
+	if workers <= 1 || !spaceAligned(t) {
+		return countBufio(ctx, in, t, ngram, dst)
 	}
+	if blockSize <= 0 {
+		blockSize = concurrentBlockSize
+	}
+	ngram = max(ngram, 1)
+
+	jobs := make(chan block, workers)
+	free := make(chan []byte, 2*workers) // посчитанные блоки - на переиспользование
+	merge := mergerFor(dst)
+	errs := make([]error, workers)
 
-	shards := make([]shard, workers)
+	var (
+		edgesMu sync.Mutex
+		edges   []blockEdges
+	)
 
 	var wg sync.WaitGroup
 	wg.Add(workers)
-
-	pool := sync.Pool{
-		New: func() any {
-			return make([]string, 0, batchSize)
-		},
-	}
-
 	for i := 0; i < workers; i++ {
-		shards[i] = shard{
-			ch: make(chan []string, 8),
-			m:  make(map[string]int),
-		}
-
-		go func(sh *shard) {
+		go func(i int) {
 			defer wg.Done()
-			for batch := range sh.ch {
-				for _, w := range batch {
-					sh.m[w]++
+			counts := NewMapCounter()
+			for b := range jobs {
+				if errs[i] != nil {
+					continue // дочитываем jobs
 				}
-				batch = batch[:0]
-				pool.Put(batch)
+				c := chunkCounter{ctx: ctx, t: t, win: newNgramWindow(ngram), counts: counts, headN: ngram - 1}
+				_, errs[i] = c.feed(b.data, -1)
+				select {
+				case free <- b.data: // в counts и edges - копии
+				default:
+				}
+				if errs[i] != nil || ngram == 1 {
+					continue
+				}
+				edgesMu.Lock()
+				if b.idx >= len(edges) {
+					edges = slices.Grow(edges, b.idx+1-len(edges))[:b.idx+1]
+				}
+				edges[b.idx] = blockEdges{head: c.head, tail: c.win.last(ngram - 1)}
+				edgesMu.Unlock()
+			}
+			if errs[i] == nil {
+				errs[i] = merge(counts)
 			}
-		}(&shards[i])
+		}(i)
 	}
 
-	closeAll := func() {
-		for i := 0; i < workers; i++ {
-			close(shards[i].ch)
-		}
-		wg.Wait()
-	}
+	readErr := readBlocks(ctx, in, blockSize, jobs, free)
+	close(jobs)
+	wg.Wait()
 
-	bufs := make([][]string, workers)
-	for i := 0; i < workers; i++ {
-		bufs[i] = pool.Get().([]string)[:0]
+	if readErr != nil {
+		return readErr
 	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	countCrossingNgrams(adderFor(dst), edges, ngram)
+	return nil
+}
 
-	flush := func(i int) error {
-		if len(bufs[i]) == 0 {
-			return nil
+// n-граммы, которые кончаются в первых ngram-1 токенах блока, воркер не считал -
+// добираем их из краёв блоков по порядку
+func countCrossingNgrams(out keyAdder, edges []blockEdges, ngram int) {
+	if ngram <= 1 {
+		return
+	}
+	win := newNgramWindow(ngram)
+	for _, e := range edges {
+		for _, tok := range e.head {
+			out.add(win.push(tok))
 		}
+		if len(e.head) == ngram-1 {
+			// блок не короче ngram-1 токенов: окно продолжается с его хвоста
+			win = newNgramWindow(ngram)
+			for _, tok := range e.tail {
+				win.push(tok)
+			}
+		}
+	}
+}
 
-		batch := bufs[i]
-		bufs[i] = pool.Get().([]string)[:0]
-
+func readBlocks(ctx context.Context, in *bufio.Reader, blockSize int, jobs chan<- block, free <-chan []byte) error {
+	var carry []byte
+	for idx := 0; ; idx++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case shards[i].ch <- batch:
-			return nil
+		default:
 		}
-	}
 
-	for {
+		var buf []byte
 		select {
-		case <-ctx.Done():
-			closeAll()
-			return nil, ctx.Err()
+		case buf = <-free:
 		default:
 		}
-
-		s, ok, err := ReadWord(in)
-		if err != nil {
-			closeAll()
-			return nil, err
+		data, rest, err := readBlock(in, buf, carry, blockSize)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("read block: %w", err)
 		}
-		if !ok {
-			break
+		carry = append(carry[:0], rest...)
+		select {
+		case jobs <- block{idx: idx, data: data}:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-
-		idx := int(hash32(s) % uint32(workers))
-		bufs[idx] = append(bufs[idx], s)
-		if len(bufs[idx]) >= batchSize {
-			if err := flush(idx); err != nil {
-				closeAll()
-				return nil, err
-			}
+		if err == io.EOF {
+			return nil
 		}
 	}
+}
 
-	for i := 0; i < workers; i++ {
-		if err := flush(i); err != nil {
-			closeAll()
-			return nil, err
-		}
+// readBlock читает не меньше size байт после carry и режет после последнего пробела;
+// остаток возвращается для следующего блока. Блок без пробелов растёт.
+func readBlock(in *bufio.Reader, buf, carry []byte, size int) (data, rest []byte, err error) {
+	b := buf[:0]
+	if need := max(size, 2*len(carry)); cap(b) < need {
+		b = make([]byte, 0, need)
 	}
-
-	closeAll()
-
-	out := make(map[string]int)
-	for i := 0; i < workers; i++ {
-		for k, v := range shards[i].m {
-			out[k] += v
+	b = append(b, carry...)
+	checked := 0
+	for {
+		for len(b) < cap(b) {
+			n, err := in.Read(b[len(b):cap(b)])
+			b = b[:len(b)+n]
+			if errors.Is(err, io.EOF) {
+				return b, nil, io.EOF
+			}
+			if err != nil {
+				return nil, nil, err
+			}
 		}
+		for i := len(b) - 1; i >= checked; i-- {
+			if isSpace(b[i]) {
+				return b[:i+1], b[i+1:], nil
+			}
+		}
+		checked = len(b)
+		b = slices.Grow(b, len(b))
 	}
-	return out, nil
-}
-
-func hash32(s string) uint32 {
-	// FNV-1a 32-bit
-	var h uint32 = 2166136261
-	for i := 0; i < len(s); i++ {
-		h ^= uint32(s[i])
-		h *= 16777619
-	}
-	return h
 }
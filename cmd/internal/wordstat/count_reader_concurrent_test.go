@@ -0,0 +1,71 @@
+package wordstat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountBufioConcurrentBlocks_MatchesSequential(t *testing.T) {
+	tokenizers := map[string]Tokenizer{
+		"whitespace": WhitespaceTokenizer,
+		"unicode":    UnicodeTokenizer,
+		"typed":      TypedTokenizer,
+		"char2":      NewCharTokenizer(true, 2),
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 20; i++ {
+		input := randomText(rng, rng.Intn(300))
+		for name, tok := range tokenizers {
+			for ngram := 1; ngram <= 4; ngram++ {
+				want, err := counted(func(c Counter) error {
+					return countBufio(ctx, bufio.NewReader(strings.NewReader(input)), tok, ngram, c)
+				})
+				if err != nil {
+					t.Fatalf("countBufio error = %v", err)
+				}
+				for _, blockSize := range []int{1, 7, 64} {
+					got, err := counted(func(c Counter) error {
+						return countBufioConcurrent(ctx, bufio.NewReader(strings.NewReader(input)), 3, blockSize, tok, ngram, c)
+					})
+					if err != nil {
+						t.Fatalf("countBufioConcurrent error = %v", err)
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s ngram=%d block=%d input=%q:\ngot=%q\nwant=%q", name, ngram, blockSize, input, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("disk on fire")
+	}
+	r.n--
+	return copy(p, "word "), nil
+}
+
+func TestCountBufioConcurrentBlocks_Errors(t *testing.T) {
+	_, err := CountBufioConcurrentBlocks(context.Background(), bufio.NewReaderSize(&failingReader{n: 100}, 16), 4, 8)
+	if err == nil || !strings.Contains(err.Error(), "disk on fire") {
+		t.Fatalf("err=%v want read error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = CountBufioConcurrentBlocks(ctx, bufio.NewReader(strings.NewReader("a b c")), 4, 8)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v want context.Canceled", err)
+	}
+}
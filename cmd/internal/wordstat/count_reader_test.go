@@ -0,0 +1,81 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWindowScanner_MatchesCountBytes(t *testing.T) {
+	tokenizers := map[string]Tokenizer{
+		"whitespace": WhitespaceTokenizer,
+		"unicode":    UnicodeTokenizer,
+		"typed":      TypedTokenizer,
+		"char3":      NewCharTokenizer(false, 3),
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(13))
+
+	for i := 0; i < 20; i++ {
+		text := randomText(rng, rng.Intn(300))
+		if i%4 == 0 {
+			text += " " + strings.Repeat("long", 20+rng.Intn(50)) + " tail"
+		}
+		for name, tok := range tokenizers {
+			for ngram := 1; ngram <= 2; ngram++ {
+				want, err := counted(func(c Counter) error { return countBytes(ctx, []byte(text), tok, ngram, c) })
+				if err != nil {
+					t.Fatalf("countBytes error = %v", err)
+				}
+				// 16 is the smallest bufio buffer: most tokens cross its edge
+				readers := map[string]*bufio.Reader{
+					"small":   bufio.NewReaderSize(strings.NewReader(text), 16),
+					"onebyte": bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(text)), 16),
+					"dataerr": bufio.NewReader(iotest.DataErrReader(strings.NewReader(text))),
+				}
+				for rname, in := range readers {
+					got, err := counted(func(c Counter) error { return countBufio(ctx, in, tok, ngram, c) })
+					if err != nil {
+						t.Fatalf("countBufio error = %v", err)
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s/%s ngram=%d input=%q:\ngot=%q\nwant=%q", name, rname, ngram, text, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWindowScanner_ReadError(t *testing.T) {
+	boom := errors.New("boom")
+	in := bufio.NewReaderSize(iotest.TimeoutReader(bytes.NewReader([]byte("a b c d e f g h i j k l m n o p q"))), 16)
+	_, err := CountBufio(context.Background(), in)
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Fatalf("err=%v want %v", err, iotest.ErrTimeout)
+	}
+
+	in = bufio.NewReader(iotest.ErrReader(boom))
+	if _, err := CountBufio(context.Background(), in); !errors.Is(err, boom) {
+		t.Fatalf("err=%v want %v", err, boom)
+	}
+}
+
+func TestCountBufio_AllocsPerWord(t *testing.T) {
+	text := strings.Repeat("alpha beta gamma Delta ", 5000)
+	allocs := testing.AllocsPerRun(5, func() {
+		if _, err := CountBufio(context.Background(), bufio.NewReader(strings.NewReader(text))); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// counter, map, the result and the four words: nothing per token
+	if allocs > 40 {
+		t.Fatalf("CountBufio made %v allocations for 20000 words", allocs)
+	}
+}
@@ -0,0 +1,291 @@
+package wordstat
+
+import (
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// Counter - куда считают движки и из чего строится отчёт.
+type Counter interface {
+	Add(word string)
+	AddN(word string, n int)
+	// точные счётчики не принимают приближённые
+	Merge(o Counter) error
+	Len() int
+	Range(yield func(word string, n int) bool)
+}
+
+var (
+	_ Counter = (*MapCounter)(nil)
+	_ Counter = (*ShardedCounter)(nil)
+	_ Counter = (*SpaceSaving)(nil)
+)
+
+// встроенные счётчики принимают ключи как []byte без копирования
+func adderFor(c Counter) keyAdder {
+	if a, ok := c.(keyAdder); ok {
+		return a
+	}
+	return counterAdder{c}
+}
+
+type counterAdder struct{ c Counter }
+
+func (a counterAdder) add(key []byte) {
+	if len(key) > 0 {
+		a.c.Add(string(key))
+	}
+}
+
+func counted(count func(Counter) error) (map[string]int, error) {
+	c := NewMapCounter()
+	if err := count(c); err != nil {
+		return nil, err
+	}
+	return c.Map(), nil
+}
+
+func mergeRange(dst, o Counter) error {
+	if _, ok := o.(*SpaceSaving); ok {
+		return fmt.Errorf("merge counter: cannot add approximate %T counts to %T", o, dst)
+	}
+	for w, n := range o.Range {
+		dst.AddN(w, n)
+	}
+	return nil
+}
+
+// topEntries - первые opts.K записей c (все при K == 0), без сортировки
+func topEntries(c Counter, opts Options) []Entry {
+	if s, ok := c.(*SpaceSaving); ok {
+		return topOf(slices.Values(s.Entries()), opts)
+	}
+	return topOf(entriesOf(c.Range), opts)
+}
+
+func topOf(entries iter.Seq[Entry], opts Options) []Entry {
+	if opts.K > 0 {
+		return selectTop(entries, opts)
+	}
+	var out []Entry
+	for e := range entries {
+		if e.Count >= opts.Min {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func entriesOf(words iter.Seq2[string, int]) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		for w, n := range words {
+			if !yield(Entry{Word: w, Count: n}) {
+				return
+			}
+		}
+	}
+}
+
+// MapCounter: map хранит индексы в counts, поэтому поиск уже встреченного
+// слова не аллоцирует, а ключ из переиспользуемого буфера не попадает в map.
+type MapCounter struct {
+	idx    map[string]int
+	words  []string
+	counts []int
+}
+
+func NewMapCounter() *MapCounter {
+	return &MapCounter{idx: make(map[string]int)}
+}
+
+func (c *MapCounter) add(b []byte) {
+	if i, ok := c.idx[string(b)]; ok {
+		c.counts[i]++
+		return
+	}
+	c.AddN(string(b), 1)
+}
+
+func (c *MapCounter) Add(word string) { c.AddN(word, 1) }
+
+func (c *MapCounter) AddN(word string, n int) {
+	if word == "" {
+		return
+	}
+	if i, ok := c.idx[word]; ok {
+		c.counts[i] += n
+		return
+	}
+	c.idx[word] = len(c.counts)
+	c.words = append(c.words, word)
+	c.counts = append(c.counts, n)
+}
+
+func (c *MapCounter) Merge(o Counter) error {
+	if o, ok := o.(*MapCounter); ok {
+		for i, w := range o.words {
+			c.AddN(w, o.counts[i])
+		}
+		return nil
+	}
+	return mergeRange(c, o)
+}
+
+func (c *MapCounter) Len() int { return len(c.words) }
+
+func (c *MapCounter) Range(yield func(word string, n int) bool) {
+	for i, w := range c.words {
+		if !yield(w, c.counts[i]) {
+			return
+		}
+	}
+}
+
+func (c *MapCounter) Map() map[string]int {
+	out := make(map[string]int, len(c.words))
+	for i, w := range c.words {
+		out[w] = c.counts[i]
+	}
+	return out
+}
+
+// ShardedCounter - точный счётчик для нескольких горутин: шарды по хешу,
+// у каждого свой мьютекс. Range нельзя вызывать во время Add.
+type ShardedCounter struct {
+	seed   maphash.Seed
+	shards []counterShard
+}
+
+type counterShard struct {
+	mu sync.Mutex
+	c  *MapCounter
+	_  [40]byte // мьютексы соседних шардов - в разных кэш-линиях
+}
+
+func NewShardedCounter(shards int) *ShardedCounter {
+	s := &ShardedCounter{seed: maphash.MakeSeed(), shards: make([]counterShard, max(shards, 1))}
+	for i := range s.shards {
+		s.shards[i].c = NewMapCounter()
+	}
+	return s
+}
+
+func (s *ShardedCounter) shardOf(word string) int {
+	return int(maphash.String(s.seed, word) % uint64(len(s.shards)))
+}
+
+func (s *ShardedCounter) add(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	sh := &s.shards[maphash.Bytes(s.seed, b)%uint64(len(s.shards))]
+	sh.mu.Lock()
+	sh.c.add(b)
+	sh.mu.Unlock()
+}
+
+func (s *ShardedCounter) Add(word string) { s.AddN(word, 1) }
+
+func (s *ShardedCounter) AddN(word string, n int) {
+	if word == "" {
+		return
+	}
+	sh := &s.shards[s.shardOf(word)]
+	sh.mu.Lock()
+	sh.c.AddN(word, n)
+	sh.mu.Unlock()
+}
+
+func (s *ShardedCounter) Merge(o Counter) error {
+	src, ok := o.(*MapCounter)
+	if !ok {
+		return mergeRange(s, o)
+	}
+	shardOf := make([]int, len(src.words))
+	for i, w := range src.words {
+		shardOf[i] = s.shardOf(w)
+	}
+	for j := range s.shards {
+		sh := &s.shards[j]
+		sh.mu.Lock()
+		for i, w := range src.words {
+			if shardOf[i] == j {
+				sh.c.AddN(w, src.counts[i])
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *ShardedCounter) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += sh.c.Len()
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+func (s *ShardedCounter) Range(yield func(word string, n int) bool) {
+	for i := range s.shards {
+		for w, n := range s.shards[i].c.Range {
+			if !yield(w, n) {
+				return
+			}
+		}
+	}
+}
+
+// в ShardedCounter воркеры сливают параллельно, в остальные - по очереди
+func mergerFor(dst Counter) func(Counter) error {
+	if _, ok := dst.(*ShardedCounter); ok {
+		return dst.Merge
+	}
+	var mu sync.Mutex
+	return func(c Counter) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return dst.Merge(c)
+	}
+}
+
+func counterFor(opts Options) Counter {
+	if workers := engineWorkers(opts); workers > 1 {
+		return NewShardedCounter(4 * workers)
+	}
+	return NewMapCounter()
+}
+
+// counterEntries: без стоп-слов, с группировкой по основам; для top-K строится только K записей
+func counterEntries(c Counter, opts Options) []Entry {
+	sel := opts
+	if !topKDirect(opts) {
+		sel.K = 0
+	}
+	stem, _ := stemmerFor(opts.Stem)
+	if len(opts.Stopwords) == 0 && stem == nil {
+		return topEntries(c, sel)
+	}
+
+	words := iter.Seq2[string, int](c.Range)
+	if sw := opts.Stopwords; len(sw) > 0 {
+		all := words
+		words = func(yield func(string, int) bool) {
+			for w, n := range all {
+				if !isStopKey([]byte(w), sw, opts.Ngram) && !yield(w, n) {
+					return
+				}
+			}
+		}
+	}
+	if stem != nil {
+		return topOf(slices.Values(buildStemmedEntries(words, stemNgram(stem, opts.Ngram))), sel)
+	}
+	return topOf(entriesOf(words), sel)
+}
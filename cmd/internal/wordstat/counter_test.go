@@ -0,0 +1,134 @@
+package wordstat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// прячет быстрый путь []byte, как Counter вне пакета
+type plainCounter struct{ Counter }
+
+func TestCounters_Exact(t *testing.T) {
+	rng := rand.New(rand.NewSource(17))
+	words := make([]string, 5000)
+	want := map[string]int{}
+	for i := range words {
+		words[i] = fmt.Sprint("w", rng.Intn(700))
+		want[words[i]]++
+	}
+
+	for name, c := range map[string]Counter{
+		"map":     NewMapCounter(),
+		"sharded": NewShardedCounter(8),
+	} {
+		// four goroutines with their own counters merge into a shared one
+		shared := NewShardedCounter(3)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				local := NewMapCounter()
+				for i := g; i < len(words); i += 4 {
+					local.Add(words[i])
+					shared.Add(words[i])
+				}
+				if err := shared.Merge(local); err != nil {
+					t.Error(err)
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		if err := c.Merge(shared); err != nil {
+			t.Fatalf("%s: Merge() error = %v", name, err)
+		}
+		c.AddN("extra", 3)
+		c.Add("")
+
+		got := map[string]int{}
+		for w, n := range c.Range {
+			got[w] = n
+		}
+		wantAll := map[string]int{"extra": 3}
+		for w, n := range want {
+			wantAll[w] = 2 * n
+		}
+		if !reflect.DeepEqual(got, wantAll) {
+			t.Fatalf("%s: counts differ", name)
+		}
+		if c.Len() != len(wantAll) {
+			t.Fatalf("%s: Len()=%d want %d", name, c.Len(), len(wantAll))
+		}
+
+		opts := Options{SortBy: "count", K: 3}
+		top := topEntries(c, opts)
+		SortEntries(top, opts)
+		full := BuildEntries(wantAll)
+		SortEntries(full, opts)
+		if !reflect.DeepEqual(top, full[:3]) {
+			t.Fatalf("%s: topEntries()=%v want %v", name, top, full[:3])
+		}
+		if all := topEntries(c, Options{SortBy: "word", Min: 2}); len(all) != len(wantAll) {
+			t.Fatalf("%s: topEntries(K=0) returned %d entries", name, len(all))
+		}
+	}
+}
+
+func TestCounters_Approximate(t *testing.T) {
+	// two full summaries: every bound still holds after merging
+	left, right := NewSpaceSaving(2), NewSpaceSaving(2)
+	for _, w := range strings.Fields("a a a b c c d") {
+		left.Add(w)
+	}
+	for _, w := range strings.Fields("a e e e b b c") {
+		right.Add(w)
+	}
+	if err := left.Merge(right); err != nil {
+		t.Fatalf("SpaceSaving.Merge() error = %v", err)
+	}
+	truth := map[string]int{"a": 4, "b": 3, "c": 3, "d": 1, "e": 3}
+	for _, e := range left.Entries() {
+		if n := truth[e.Word]; e.Count-e.Error > n || n > e.Count {
+			t.Fatalf("%s: true count %d outside [%d, %d]", e.Word, n, e.Count-e.Error, e.Count)
+		}
+	}
+	if left.Total() != 14 || left.Len() != 2 {
+		t.Fatalf("Total()=%d Len()=%d", left.Total(), left.Len())
+	}
+
+	for _, c := range []Counter{NewMapCounter(), NewShardedCounter(2)} {
+		if err := c.Merge(left); err == nil {
+			t.Fatalf("%T.Merge(SpaceSaving): expected error", c)
+		}
+	}
+}
+
+func TestEngines_CountIntoAnyCounter(t *testing.T) {
+	input := strings.Repeat("b a b c ", 100)
+	want := map[string]int{"a": 100, "b": 200, "c": 100}
+
+	for name, c := range map[string]Counter{
+		"sharded": NewShardedCounter(4),
+		"plain":   plainCounter{NewMapCounter()},
+	} {
+		ctx := context.Background()
+		err := countBufioConcurrent(ctx, bufio.NewReader(strings.NewReader(input)), 3, 16, WhitespaceTokenizer, 1, c)
+		if err != nil {
+			t.Fatalf("%s: error = %v", name, err)
+		}
+		got := map[string]int{}
+		for w, n := range c.Range {
+			got[w] = n
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got=%v want=%v", name, got, want)
+		}
+	}
+}
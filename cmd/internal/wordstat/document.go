@@ -0,0 +1,43 @@
+package wordstat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Data - весь вход в памяти (например, mmap), считается на месте, R тогда не нужен.
+// Size - размер в байтах (0 - неизвестен), по нему выбирает -engine=auto.
+type Document struct {
+	Name string
+	R    io.Reader
+	Data []byte
+	Size int64
+}
+
+func SingleDocument(name string, r io.Reader) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		yield(Document{Name: name, R: r}, nil)
+	}
+}
+
+// MapDocuments оборачивает каждый документ в wrap.
+func MapDocuments(docs iter.Seq2[Document, error], wrap func(io.Reader) (io.Reader, error)) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		for doc, err := range docs {
+			if err == nil {
+				if doc.Data != nil {
+					doc.R, doc.Data, doc.Size = bytes.NewReader(doc.Data), nil, int64(len(doc.Data))
+				}
+				doc.R, err = wrap(doc.R)
+				if err != nil {
+					err = fmt.Errorf("%s: %w", doc.Name, err)
+				}
+			}
+			if !yield(doc, err) || err != nil {
+				return
+			}
+		}
+	}
+}
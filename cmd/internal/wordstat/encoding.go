@@ -0,0 +1,204 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// сколько байт смотрит определение кодировки
+const sniffLen = 4096
+
+var encodings = map[string]encoding.Encoding{
+	"utf-8":    nil,
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"cp1251":   charmap.Windows1251,
+	"koi8-r":   charmap.KOI8R,
+}
+
+var encodingAliases = map[string]string{
+	"utf8":         "utf-8",
+	"us-ascii":     "utf-8",
+	"utf-16":       "utf-16le",
+	"windows-1251": "cp1251",
+	"win-1251":     "cp1251",
+	"koi8r":        "koi8-r",
+}
+
+// CanonicalEncoding приводит имя кодировки (или charset) к каноническому; пустое - utf-8.
+func CanonicalEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "utf-8", nil
+	}
+	if name == "auto" {
+		return name, nil
+	}
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := encodings[name]; !ok {
+		return "", fmt.Errorf("unsupported encoding %q (use auto|utf-8|utf-16le|utf-16be|cp1251|koi8-r)", name)
+	}
+	return name, nil
+}
+
+func NewDecodingReader(r io.Reader, name string) (io.Reader, error) {
+	name, err := CanonicalEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "auto" || name == "utf-8" {
+		br := bufio.NewReaderSize(r, sniffLen)
+		sample, err := br.Peek(sniffLen)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, fmt.Errorf("detect encoding: %w", err)
+		}
+		name, _ = SniffEncoding(sample, name)
+		r = br
+	}
+	enc := encodings[name]
+	if enc == nil {
+		return r, nil
+	}
+	return transform.NewReader(r, enc.NewDecoder()), nil
+}
+
+// SniffEncoding: utf-8 с BOM UTF-16 читается как UTF-16, auto определяется по sample.
+func SniffEncoding(sample []byte, name string) (string, error) {
+	name, err := CanonicalEncoding(name)
+	if err != nil {
+		return "", err
+	}
+	switch name {
+	case "auto":
+		return DetectEncoding(sample), nil
+	case "utf-8":
+		if enc, ok := bomEncoding(sample); ok {
+			return enc, nil
+		}
+	}
+	return name, nil
+}
+
+func DetectEncoding(sample []byte) string {
+	if enc, ok := bomEncoding(sample); ok {
+		return enc
+	}
+	if enc, ok := detectUTF16(sample); ok {
+		return enc
+	}
+	if validUTF8Prefix(sample) {
+		return "utf-8"
+	}
+
+	high := 0
+	for _, c := range sample {
+		if c >= 0x80 {
+			high++
+		}
+	}
+	// частые русские буквы должны явно перевешивать в одной из кодировок
+	koi8, cp1251 := russianScore(charmap.KOI8R, sample), russianScore(charmap.Windows1251, sample)
+	switch {
+	case koi8 > 2*cp1251 && 3*koi8 >= high:
+		return "koi8-r"
+	case cp1251 > 2*koi8 && 3*cp1251 >= high:
+		return "cp1251"
+	}
+	return "utf-8"
+}
+
+func bomEncoding(sample []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return "utf-8", true
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return "utf-16le", true
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return "utf-16be", true
+	}
+	return "", false
+}
+
+// LooksBinary: NUL-байты (не UTF-16) или больше 10% управляющих символов.
+func LooksBinary(sample []byte) bool {
+	switch enc := DetectEncoding(sample); enc {
+	case "utf-16le", "utf-16be":
+		sample, _, _ = transform.Bytes(encodings[enc].NewDecoder(), sample)
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	ctrl := 0
+	for _, c := range sample {
+		switch {
+		case c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v' || c == 0x1b:
+		case c < 0x20 || c == 0x7f:
+			ctrl++
+		}
+	}
+	return ctrl*10 > len(sample)
+}
+
+// utf8.Valid, но руна может быть обрезана в конце sample
+func validUTF8Prefix(b []byte) bool {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				b = b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// старшие байты латиницы (0x00) и кириллицы (0x04): нечётные смещения в LE, чётные в BE
+func detectUTF16(b []byte) (string, bool) {
+	if bytes.IndexByte(b, 0) < 0 {
+		return "", false
+	}
+	var even, odd int
+	for i, c := range b {
+		if c != 0x00 && c != 0x04 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	units := len(b) / 2
+	switch {
+	case odd > units/2 && odd > 2*even:
+		return "utf-16le", true
+	case even > units/2 && even > 2*odd:
+		return "utf-16be", true
+	default:
+		return "", false
+	}
+}
+
+func russianScore(cm *charmap.Charmap, b []byte) int {
+	score := 0
+	for _, c := range b {
+		if c < 0x80 {
+			continue
+		}
+		if strings.ContainsRune("оеаинтсрвл", cm.DecodeByte(c)) {
+			score++
+		}
+	}
+	return score
+}
@@ -0,0 +1,156 @@
+package wordstat
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const russianSample = "Привет, мир! Это просто текст для проверки кодировки, слово слово"
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := map[string][]byte{
+		"utf-8":    []byte(russianSample),
+		"cp1251":   encode(t, charmap.Windows1251, russianSample),
+		"koi8-r":   encode(t, charmap.KOI8R, russianSample),
+		"utf-16le": encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), russianSample),
+		"utf-16be": encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "plain ascii text"),
+	}
+	for want, sample := range tests {
+		if got := DetectEncoding(sample); got != want {
+			t.Errorf("DetectEncoding(%s sample) = %q", want, got)
+		}
+	}
+
+	// a rune cut at the end of the sample is still UTF-8
+	cut := []byte(russianSample)[:3]
+	if got := DetectEncoding(cut); got != "utf-8" {
+		t.Errorf("DetectEncoding(cut utf-8) = %q", got)
+	}
+	if got := DetectEncoding([]byte{0xFE, 0xFF, 0x04, 0x1F}); got != "utf-16be" {
+		t.Errorf("DetectEncoding(utf-16be bom) = %q", got)
+	}
+	// ни одна кириллическая кодировка явно не выигрывает - вход не перекодируется
+	latin1 := encode(t, charmap.ISO8859_1, "À la carte: crème brûlée, café au lait, pâté, naïveté")
+	if got := DetectEncoding(latin1); got != "utf-8" {
+		t.Errorf("DetectEncoding(latin-1) = %q", got)
+	}
+}
+
+func TestNewDecodingReader(t *testing.T) {
+	withBOM := encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), russianSample)
+	for _, tt := range []struct {
+		enc  string
+		data []byte
+	}{
+		{"auto", withBOM},
+		{"utf-16le", withBOM},
+		{"utf-8", withBOM},
+		{"", withBOM},
+		{"auto", encode(t, charmap.KOI8R, russianSample)},
+		{"windows-1251", encode(t, charmap.Windows1251, russianSample)},
+		{"utf-8", []byte(russianSample)},
+	} {
+		r, err := NewDecodingReader(bytes.NewReader(tt.data), tt.enc)
+		if err != nil {
+			t.Fatalf("NewDecodingReader(%q) error = %v", tt.enc, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read (%q) error = %v", tt.enc, err)
+		}
+		if string(got) != russianSample {
+			t.Fatalf("decoded (%q) = %q", tt.enc, got)
+		}
+	}
+
+	if _, err := NewDecodingReader(strings.NewReader(""), "latin-9"); err == nil {
+		t.Fatalf("expected error for unsupported encoding")
+	}
+}
+
+func TestHTTPWordstat_Charset(t *testing.T) {
+	h := NewHTTPMux()
+
+	body := encode(t, charmap.Windows1251, "слово Слово мир")
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain; charset=windows-1251")
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	want := "слово 2\nмир 1\n"
+	if rr.Body.String() != want {
+		t.Fatalf("got=%q want=%q", rr.Body.String(), want)
+	}
+}
+
+func TestHTTPWordstat_BOMWithoutCharset(t *testing.T) {
+	h := NewHTTPMux()
+
+	body := encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "слово Слово мир")
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if want := "слово 2\nмир 1\n"; rr.Code != http.StatusOK || rr.Body.String() != want {
+		t.Fatalf("status=%d got=%q want=%q", rr.Code, rr.Body.String(), want)
+	}
+}
+
+func TestHTTPWordstat_UnsupportedCharset(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat", strings.NewReader("a"))
+	req.Header.Set("Content-Type", "text/plain; charset=ebcdic")
+	req.Header.Set("X-Request-Id", "rid-charset")
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	_ = requireJSONError(t, rr, "rid-charset")
+}
+
+func TestLooksBinary(t *testing.T) {
+	tests := map[string]struct {
+		sample []byte
+		want   bool
+	}{
+		"utf-8":       {[]byte(russianSample + "\n\tend\r\n"), false},
+		"cp1251":      {encode(t, charmap.Windows1251, russianSample), false},
+		"utf-16le":    {encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), russianSample), false},
+		"empty":       {nil, false},
+		"nul":         {[]byte("text\x00more text"), true},
+		"control":     {[]byte("\x01\x02\x03\x04 ab"), true},
+		"png header":  {[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), true},
+		"utf-16 ctrl": {[]byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 'a', 0x00}, true},
+	}
+	for name, tt := range tests {
+		if got := LooksBinary(tt.sample); got != tt.want {
+			t.Errorf("LooksBinary(%s) = %v, want %v", name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package wordstat
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+)
+
+// до скольких байт auto читает вход целиком (или четверть GOMEMLIMIT)
+const autoBufferedMax = 64 << 20
+
+type EngineChoice struct {
+	Document string `json:"document"`
+	Size     int64  `json:"size,omitempty"` // 0 = unknown
+	Engine   string `json:"engine"`         // stream, buffered, concurrent, mapped, pattern, spill
+	Workers  int    `json:"workers"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func validateEngine(engine string) error {
+	switch engine {
+	case "", "auto", "stream", "buffered", "concurrent":
+		return nil
+	default:
+		return fmt.Errorf("invalid -engine=%q (use auto|stream|buffered|concurrent)", engine)
+	}
+}
+
+func chooseEngine(doc Document, re *regexp.Regexp, opts Options) EngineChoice {
+	c := EngineChoice{Document: doc.Name, Size: doc.Size, Workers: engineWorkers(opts)}
+	if doc.Data != nil {
+		c.Size = int64(len(doc.Data))
+	}
+	switch {
+	case re != nil:
+		c.Engine, c.Workers = "pattern", 1
+	case doc.Data != nil:
+		c.Engine = "mapped"
+	case opts.Engine == "auto":
+		c = chooseAuto(c)
+	case opts.Engine != "":
+		c.Engine = opts.Engine
+	case opts.Buffered:
+		c.Engine = "buffered"
+	case c.Workers > 1:
+		c.Engine = "concurrent"
+	default:
+		c.Engine = "stream"
+	}
+	if c.Engine == "stream" {
+		c.Workers = 1
+	}
+	return c
+}
+
+// Workers == 0: для auto и concurrent - по числу CPU, иначе один
+func engineWorkers(opts Options) int {
+	if opts.Workers == 0 && (opts.Engine == "auto" || opts.Engine == "concurrent") {
+		return runtime.GOMAXPROCS(0)
+	}
+	return max(opts.Workers, 1)
+}
+
+func chooseAuto(c EngineChoice) EngineChoice {
+	budget := int64(autoBufferedMax)
+	if limit := debug.SetMemoryLimit(-1); limit/4 < budget {
+		budget = limit / 4
+	}
+
+	switch {
+	case c.Size > 0 && c.Size <= budget:
+		c.Engine = "buffered"
+		c.Reason = fmt.Sprintf("%d bytes fit the %d byte budget", c.Size, budget)
+	case c.Workers > 1:
+		c.Engine = "concurrent"
+		c.Reason = fmt.Sprintf("%s size, %d workers", sizeWord(c.Size), c.Workers)
+	default:
+		c.Engine = "stream"
+		c.Reason = fmt.Sprintf("%s size, 1 worker", sizeWord(c.Size))
+	}
+	return c
+}
+
+func reportEngine(c EngineChoice, opts Options) {
+	if opts.OnEngine != nil {
+		opts.OnEngine(c)
+	}
+}
+
+// движок однопроходных режимов (-approx, -sketch, -max-mem)
+func streamChoice(doc Document, engine, reason string) EngineChoice {
+	c := EngineChoice{Document: doc.Name, Size: doc.Size, Engine: engine, Workers: 1, Reason: reason}
+	if doc.Data != nil {
+		c.Size = int64(len(doc.Data))
+	}
+	return c
+}
+
+func sizeWord(size int64) string {
+	if size > 0 {
+		return "large"
+	}
+	return "unknown"
+}
@@ -0,0 +1,112 @@
+package wordstat
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"iter"
+	"math"
+	"math/rand"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChooseEngine(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(math.MaxInt64))
+
+	re := regexp.MustCompile(`\w+`)
+	tests := []struct {
+		name        string
+		doc         Document
+		re          *regexp.Regexp
+		opts        Options
+		engine      string
+		workers     int
+		procs       int
+		memoryLimit int64
+	}{
+		{name: "legacy stream", opts: Options{Workers: 1}, engine: "stream", workers: 1},
+		{name: "legacy buffered", opts: Options{Workers: 2, Buffered: true}, engine: "buffered", workers: 2},
+		{name: "legacy concurrent", opts: Options{Workers: 3}, engine: "concurrent", workers: 3},
+		{name: "forced stream", opts: Options{Workers: 3, Engine: "stream"}, engine: "stream", workers: 1},
+		{name: "forced concurrent", opts: Options{Engine: "concurrent"}, engine: "concurrent", workers: 4},
+		{name: "concurrent one worker", opts: Options{Workers: 1, Engine: "concurrent"}, engine: "concurrent", workers: 1},
+		{name: "legacy default", opts: Options{}, engine: "stream", workers: 1},
+		{name: "pattern", re: re, opts: Options{Engine: "auto"}, engine: "pattern", workers: 1},
+		{name: "data", doc: Document{Data: []byte("a b")}, opts: Options{Engine: "auto"}, engine: "mapped", workers: 4},
+
+		{name: "auto small", doc: Document{Size: 1000}, opts: Options{Engine: "auto"}, engine: "buffered", workers: 4},
+		{name: "auto workers", doc: Document{Size: 1000}, opts: Options{Engine: "auto", Workers: 2}, engine: "buffered", workers: 2},
+		{name: "auto unknown", opts: Options{Engine: "auto"}, engine: "concurrent", workers: 4},
+		{name: "auto large", doc: Document{Size: 1 << 30}, opts: Options{Engine: "auto"}, engine: "concurrent", workers: 4},
+		{name: "auto one cpu", opts: Options{Engine: "auto"}, procs: 1, engine: "stream", workers: 1},
+		{name: "auto one worker", opts: Options{Engine: "auto", Workers: 1}, engine: "stream", workers: 1},
+		{name: "auto one worker small", doc: Document{Size: 1000}, opts: Options{Engine: "auto", Workers: 1}, engine: "buffered", workers: 1},
+		{name: "auto one cpu small", doc: Document{Size: 10}, opts: Options{Engine: "auto"}, procs: 1, engine: "buffered", workers: 1},
+		{name: "auto memory limit", doc: Document{Size: 512 << 10}, opts: Options{Engine: "auto"}, memoryLimit: 1 << 20, engine: "concurrent", workers: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime.GOMAXPROCS(cmp.Or(tt.procs, 4))
+			debug.SetMemoryLimit(cmp.Or(tt.memoryLimit, math.MaxInt64))
+
+			got := chooseEngine(tt.doc, tt.re, tt.opts)
+			if got.Engine != tt.engine || got.Workers != tt.workers {
+				t.Fatalf("chooseEngine = %s/%d (%s), want %s/%d", got.Engine, got.Workers, got.Reason, tt.engine, tt.workers)
+			}
+		})
+	}
+}
+
+func TestRunDocs_EnginesAgree(t *testing.T) {
+	rng := rand.New(rand.NewSource(20))
+	texts := []string{randomText(rng, 2000), randomText(rng, 50), ""}
+	docs := func(sized bool) iter.Seq2[Document, error] {
+		return func(yield func(Document, error) bool) {
+			for i, text := range texts {
+				doc := Document{Name: string(rune('a' + i)), R: strings.NewReader(text)}
+				if sized {
+					doc.Size = int64(len(text))
+				}
+				if !yield(doc, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	var want string
+	for _, engine := range []string{"", "auto", "stream", "buffered", "concurrent"} {
+		for _, sized := range []bool{false, true} {
+			var got bytes.Buffer
+			var chosen []string
+			opts := Options{SortBy: "count", Ngram: 2, Engine: engine, OnEngine: func(c EngineChoice) {
+				chosen = append(chosen, c.Document)
+			}}
+			if err := RunDocsCtx(context.Background(), docs(sized), &got, opts); err != nil {
+				t.Fatalf("engine %q: RunDocsCtx error = %v", engine, err)
+			}
+			if want == "" {
+				want = got.String()
+			}
+			if got.String() != want {
+				t.Fatalf("engine %q (sized %v) report differs:\n%s\nwant:\n%s", engine, sized, got.String(), want)
+			}
+			if !slices.Equal(chosen, []string{"a", "b", "c"}) {
+				t.Fatalf("engine %q: OnEngine called for %q", engine, chosen)
+			}
+		}
+	}
+}
+
+func TestValidateOptions_Engine(t *testing.T) {
+	err := ValidateOptions(Options{SortBy: "word", Workers: 1, Engine: "turbo"})
+	if err == nil || !strings.Contains(err.Error(), "-engine") {
+		t.Fatalf("ValidateOptions error = %v, want invalid -engine", err)
+	}
+}
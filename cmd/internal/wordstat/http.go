@@ -1,23 +1,37 @@
 package wordstat
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"expvar"
 	"fmt"
+	"io"
+	"iter"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 const defaultMaxBodyBytes = 1 << 20
 
+const maxHTTPApproxCap = 1 << 20
+
 type apiError struct {
 	Error     string `json:"error"`
 	RequestID string `json:"request_id,omitempty"`
 }
 
+// verboseReport - ответ с verbose=true: движки по документам и обычный отчёт.
+type verboseReport struct {
+	Engines []EngineChoice  `json:"engines"`
+	Report  json.RawMessage `json:"report"`
+}
+
 func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
 	reqID, _ := GetRequestID(r.Context())
 
@@ -31,6 +45,12 @@ func writeError(w http.ResponseWriter, r *http.Request, status int, msg string)
 
 type HTTPConfig struct {
 	MaxBodyBytes int64
+
+	// лимит для approx=true; 0 - MaxBodyBytes
+	MaxApproxBodyBytes int64
+
+	// списки для stopwords=a,b; en и ru есть всегда
+	Stopwords map[string]Stopwords
 }
 
 var DefaultHTTPConfig = HTTPConfig{
@@ -44,6 +64,15 @@ func NewHTTPMux() http.Handler {
 func NewHTTPMuxWithConfig(cfg HTTPConfig) http.Handler {
 	mux := http.NewServeMux()
 
+	stopwords := make(map[string]Stopwords, len(cfg.Stopwords)+2)
+	for _, lang := range []string{"en", "ru"} {
+		sw, _ := BuiltinStopwords(lang)
+		stopwords[lang] = sw
+	}
+	for name, sw := range cfg.Stopwords {
+		stopwords[name] = sw
+	}
+
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok\n"))
@@ -57,14 +86,51 @@ func NewHTTPMuxWithConfig(cfg HTTPConfig) http.Handler {
 			return
 		}
 
-		opts, err := optionsFromQuery(r)
+		opts, err := optionsFromQuery(r, stopwords)
 		if err != nil {
 			writeError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// ограничение размер входа 1 MB
-		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
+		verbose := false
+		if v := r.URL.Query().Get("verbose"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil || b && opts.Format != "json" {
+				writeError(w, r, http.StatusBadRequest, fmt.Sprintf("bad verbose=%q (needs format=json)", v))
+				return
+			}
+			verbose = b
+		}
+
+		// ограничение размера входа (для approx - свой лимит)
+		limit := cfg.MaxBodyBytes
+		if opts.Approx && cfg.MaxApproxBodyBytes > 0 {
+			limit = cfg.MaxApproxBodyBytes
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		if err := decompressBody(w, r, limit); err != nil {
+			var mbe *http.MaxBytesError
+			switch {
+			case errors.As(err, &mbe):
+				writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			case errors.Is(err, errUnsupportedContentEncoding):
+				writeError(w, r, http.StatusUnsupportedMediaType, err.Error())
+			default:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+
+		docs, err := bodyDocuments(w, r, limit)
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			writeError(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
 
 		if opts.Format == "json" {
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -72,8 +138,22 @@ func NewHTTPMuxWithConfig(cfg HTTPConfig) http.Handler {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		}
 
-		err = RunCtx(r.Context(), r.Body, w, opts)
+		// заголовки уходят с первой записью отчёта, то есть после подсчёта
+		engines := []EngineChoice{}
+		opts.OnEngine = func(c EngineChoice) {
+			w.Header().Set("X-Wordstat-Engine", c.Engine)
+			engines = append(engines, c)
+		}
+		var report bytes.Buffer
+		out := io.Writer(w)
+		if verbose {
+			out = &report
+		}
+		err = RunDocsCtx(r.Context(), docs, out, opts)
 		if err == nil {
+			if verbose {
+				_ = json.NewEncoder(w).Encode(verboseReport{Engines: engines, Report: report.Bytes()})
+			}
 			return
 		}
 		// Если контекст уже отменён/истёк, то классифицируем
@@ -105,14 +185,110 @@ func NewHTTPMuxWithConfig(cfg HTTPConfig) http.Handler {
 	return RequestID(Logging(Recovery(mux)))
 }
 
-func optionsFromQuery(r *http.Request) (Options, error) {
+func inputFor(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "text/html", mt == "application/xhtml+xml":
+		return "html"
+	case mt == "text/xml", mt == "application/xml", strings.HasSuffix(mt, "+xml"):
+		return "xml"
+	case mt == "text/markdown", mt == "text/x-markdown":
+		return "markdown"
+	}
+	return ""
+}
+
+var errUnsupportedContentEncoding = errors.New("unsupported Content-Encoding")
+
+// лимит - на распакованный размер (zip-бомба)
+func decompressBody(w http.ResponseWriter, r *http.Request, limit int64) error {
+	switch ce := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); ce {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("bad gzip body: %w", err)
+		}
+		r.Body = http.MaxBytesReader(w, zr, limit)
+		r.ContentLength = -1 // the decompressed size is not known
+		return nil
+	default:
+		return fmt.Errorf("%w %q (use gzip)", errUnsupportedContentEncoding, ce)
+	}
+}
+
+// charset действует на все документы; без него - UTF-8 (UTF-16 с BOM), как в CLI
+func bodyDocuments(w http.ResponseWriter, r *http.Request, limit int64) (iter.Seq2[Document, error], error) {
+	var mediaType, charset string
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, fmt.Errorf("bad Content-Type=%q", ct)
+		}
+		mediaType, charset = mt, params["charset"]
+		if _, err := CanonicalEncoding(charset); charset != "" && err != nil {
+			return nil, fmt.Errorf("unsupported charset=%q", charset)
+		}
+	}
+
+	var docs iter.Seq2[Document, error]
+	switch mediaType {
+	case "application/x-tar", "application/x-gtar", "application/x-compressed-tar", "application/x-tgz":
+		dr, _, err := NewDecompressingReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		docs = TarDocuments(http.MaxBytesReader(w, io.NopCloser(dr), limit), nil)
+	case "application/zip", "application/x-zip-compressed":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		left := limit
+		docs = MapDocuments(ZipDocuments(bytes.NewReader(data), int64(len(data)), nil), func(r io.Reader) (io.Reader, error) {
+			return &budgetReader{r: r, left: &left, limit: limit}, nil
+		})
+	default:
+		doc := Document{Name: "body", R: r.Body, Size: max(r.ContentLength, 0)}
+		docs = func(yield func(Document, error) bool) { yield(doc, nil) }
+	}
+	return MapDocuments(docs, func(r io.Reader) (io.Reader, error) {
+		return NewDecodingReader(r, charset)
+	}), nil
+}
+
+// общий лимит на всех читателей left
+type budgetReader struct {
+	r     io.Reader
+	left  *int64
+	limit int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if *b.left -= int64(n); *b.left < 0 {
+		return n, &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+func optionsFromQuery(r *http.Request, stopwords map[string]Stopwords) (Options, error) {
 	q := r.URL.Query()
 
 	opts := Options{
-		SortBy: q.Get("sort"),
-		Format: q.Get("format"),
-		Min:    1,
-		K:      0,
+		SortBy:    q.Get("sort"),
+		Format:    q.Get("format"),
+		Min:       1,
+		K:         0,
+		Tokenizer: q.Get("tokenizer"),
+		Stem:      q.Get("stem"),
+		Unit:      q.Get("unit"),
+		Engine:    q.Get("engine"),
+		Normalizer: Normalizer{
+			Form:     q.Get("normalize"),
+			CaseFold: q.Get("casefold"),
+		},
 	}
 
 	if opts.SortBy == "" {
@@ -132,6 +308,109 @@ func optionsFromQuery(r *http.Request) (Options, error) {
 	default:
 		return Options{}, fmt.Errorf("bad format=%q", opts.Format)
 	}
+	if _, err := LookupTokenizer(opts.Tokenizer); err != nil {
+		return Options{}, fmt.Errorf("bad tokenizer=%q", opts.Tokenizer)
+	}
+	switch opts.Unit {
+	case "", "word", "char", "char-ngram":
+	default:
+		return Options{}, fmt.Errorf("bad unit=%q", opts.Unit)
+	}
+	if err := validateEngine(opts.Engine); err != nil {
+		return Options{}, fmt.Errorf("bad engine=%q", opts.Engine)
+	}
+	opts.Input = q.Get("input")
+	if opts.Input == "" {
+		opts.Input = inputFor(r.Header.Get("Content-Type"))
+	}
+	switch opts.Input {
+	case "", "text", "html", "xml", "markdown":
+	default:
+		return Options{}, fmt.Errorf("bad input=%q", opts.Input)
+	}
+	if v := q.Get("mdparts"); v != "" {
+		p, err := ParseMarkdownParts(v)
+		if err != nil || opts.Input != "markdown" {
+			return Options{}, fmt.Errorf("bad mdparts=%q", v)
+		}
+		opts.Markdown = p
+	}
+	if err := opts.Normalizer.Validate(); err != nil {
+		return Options{}, fmt.Errorf("bad normalize=%q or casefold=%q", opts.Normalizer.Form, opts.Normalizer.CaseFold)
+	}
+	if v := q.Get("yo"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad yo=%q", v)
+		}
+		opts.Normalizer.MergeYo = b
+	}
+	if v := q.Get("letters"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad letters=%q", v)
+		}
+		opts.LettersOnly = b
+	}
+	switch opts.Stem {
+	case "", "en", "ru":
+	default:
+		return Options{}, fmt.Errorf("bad stem=%q", opts.Stem)
+	}
+	if v := q.Get("pattern"); v != "" {
+		opts.Pattern = v
+		if g := q.Get("group"); g != "" {
+			n, err := strconv.Atoi(g)
+			if err != nil {
+				return Options{}, fmt.Errorf("bad group=%q", g)
+			}
+			opts.Group = n
+		}
+		if _, err := compilePattern(opts.Pattern, opts.Group); err != nil {
+			return Options{}, fmt.Errorf("bad pattern=%q or group=%d", opts.Pattern, opts.Group)
+		}
+		if opts.Tokenizer != "" || strings.HasPrefix(opts.Unit, "char") {
+			return Options{}, fmt.Errorf("bad pattern=%q (cannot be combined with tokenizer or unit=char)", opts.Pattern)
+		}
+	}
+	if v := q.Get("approx"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad approx=%q", v)
+		}
+		opts.Approx = b
+	}
+	if v := q.Get("approxcap"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxHTTPApproxCap {
+			return Options{}, fmt.Errorf("bad approxcap=%q (must be 0..%d)", v, maxHTTPApproxCap)
+		}
+		opts.ApproxCap = n
+	}
+	if opts.Approx && opts.Stem != "" {
+		return Options{}, fmt.Errorf("bad stem=%q (cannot be combined with approx)", opts.Stem)
+	}
+	if v := q.Get("classes"); v != "" {
+		f, err := ParseClassFilter(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad classes=%q", v)
+		}
+		opts.Classes = f
+	}
+	if v := q.Get("bydoc"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad bydoc=%q", v)
+		}
+		opts.ByDocument = b
+	}
+	if v := q.Get("byclass"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad byclass=%q", v)
+		}
+		opts.ByClass = b
+	}
 	if v := q.Get("min"); v != "" {
 		n, err := strconv.Atoi(v)
 		if err != nil {
@@ -153,5 +432,26 @@ func optionsFromQuery(r *http.Request) (Options, error) {
 		}
 		opts.K = n
 	}
+
+	if v := q.Get("ngram"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Options{}, fmt.Errorf("bad ngram=%q", v)
+		}
+		if n < 1 {
+			return Options{}, fmt.Errorf("bad ngram=%q (must be >= 1)", v)
+		}
+		opts.Ngram = n
+	}
+
+	if v := q.Get("stopwords"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			sw, ok := stopwords[name]
+			if !ok {
+				return Options{}, fmt.Errorf("bad stopwords=%q (unknown list %q)", v, name)
+			}
+			opts.Stopwords = opts.Stopwords.Merge(sw)
+		}
+	}
 	return opts, nil
 }
@@ -67,3 +67,107 @@ func TestHTTPWordstat_BadMethod(t *testing.T) {
 		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
 	}
 }
+
+func TestHTTPWordstat_UnicodeTokenizer(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&tokenizer=unicode", strings.NewReader("a, (a) b!"))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	want := "a 2\nb 1\n"
+	if rr.Body.String() != want {
+		t.Fatalf("got=%q want=%q", rr.Body.String(), want)
+	}
+}
+
+func TestHTTPWordstat_StemJSON(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&format=json&stem=ru", strings.NewReader("слово слова слова словами"))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	var got []Entry
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal error=%v body=%q", err, rr.Body.String())
+	}
+
+	want := []Entry{{Word: "слов", Count: 4, Form: "слова"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v want=%v", got, want)
+	}
+}
+
+func TestHTTPWordstat_Stopwords(t *testing.T) {
+	h := NewHTTPMuxWithConfig(HTTPConfig{
+		MaxBodyBytes: defaultMaxBodyBytes,
+		Stopwords:    map[string]Stopwords{"pets": {"cat": {}}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&stopwords=ru,pets", strings.NewReader("и cat и dog не"))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+
+	want := "dog 1\n"
+	if rr.Body.String() != want {
+		t.Fatalf("got=%q want=%q", rr.Body.String(), want)
+	}
+}
+
+func TestHTTPWordstat_Engine(t *testing.T) {
+	h := NewHTTPMux()
+
+	for engine, want := range map[string]string{"": "stream", "auto": "buffered", "concurrent": "concurrent"} {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&engine="+engine, strings.NewReader("b a a b c"))
+		rr := httptest.NewRecorder()
+
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("engine=%q: status=%d body=%q", engine, rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("X-Wordstat-Engine"); got != want {
+			t.Fatalf("engine=%q: X-Wordstat-Engine=%q want=%q", engine, got, want)
+		}
+		if rr.Body.String() != "a 2\nb 2\nc 1\n" {
+			t.Fatalf("engine=%q: body=%q", engine, rr.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?engine=auto&format=json&verbose=true", strings.NewReader("b a a"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var rep verboseReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &rep); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("verbose: status=%d body=%q: %v", rr.Code, rr.Body.String(), err)
+	}
+	if len(rep.Engines) != 1 || rep.Engines[0].Engine != "buffered" || rep.Engines[0].Size != 5 ||
+		string(rep.Report) != `[{"word":"a","count":2},{"word":"b","count":1}]` {
+		t.Fatalf("verbose: body=%q", rr.Body.String())
+	}
+
+	for _, target := range []string{"/wordstat?engine=turbo", "/wordstat?verbose=true", "/wordstat?format=json&verbose=maybe"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("a"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status=%d want 400", target, rr.Code)
+		}
+	}
+}
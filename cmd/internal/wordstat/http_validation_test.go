@@ -229,3 +229,35 @@ func extractCountsFromJSON(t *testing.T, b []byte) map[string]int {
 	t.Fatalf("unexpected json shape: %T body=%q", v, string(b))
 	return nil
 }
+
+func TestHTTPValidation_BadTokenizer(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?tokenizer=nope", strings.NewReader("a a"))
+	req.Header.Set("X-Request-Id", "rid-badtok")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d want=%d body=%q", rr.Code, http.StatusBadRequest, rr.Body.String())
+	}
+	requireReqIDHeader(t, rr, "rid-badtok")
+	_ = requireJSONError(t, rr, "rid-badtok")
+}
+
+func TestHTTPValidation_UnknownStopwords(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?stopwords=klingon", strings.NewReader("a a"))
+	req.Header.Set("X-Request-Id", "rid-badsw")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d want=%d body=%q", rr.Code, http.StatusBadRequest, rr.Body.String())
+	}
+	requireReqIDHeader(t, rr, "rid-badsw")
+	_ = requireJSONError(t, rr, "rid-badsw")
+}
@@ -0,0 +1,500 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// MarkdownParts - что считать с -input=markdown; нулевое значение - текст и заголовки.
+type MarkdownParts struct {
+	Prose    bool // абзацы, списки, цитаты, таблицы, текст ссылок
+	Headings bool
+	Code     bool // блоки кода и `код` в строке
+}
+
+func ParseMarkdownParts(s string) (MarkdownParts, error) {
+	var p MarkdownParts
+	if s == "" {
+		return p, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		switch strings.TrimSpace(item) {
+		case "prose":
+			p.Prose = true
+		case "headings":
+			p.Headings = true
+		case "code":
+			p.Code = true
+		default:
+			return MarkdownParts{}, fmt.Errorf("unknown markdown part %q (use prose|headings|code)", item)
+		}
+	}
+	return p, nil
+}
+
+func (p MarkdownParts) IsZero() bool {
+	return p == MarkdownParts{}
+}
+
+var (
+	linkDefinition = regexp.MustCompile(`^\[[^\]]+\]:\s*\S`)
+	tableDelimiter = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	autolink       = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[^\s@<>]+@[^\s@<>]+)>`)
+	htmlTag        = regexp.MustCompile(`^</?([A-Za-z][A-Za-z0-9-]*)(\s[^<>]*)?/?>`)
+)
+
+// NewMarkdownTextReader отдаёт текст Markdown-документа (CommonMark + таблицы GitHub)
+// без разметки, URL ссылок и front matter.
+func NewMarkdownTextReader(r io.Reader, parts MarkdownParts) io.Reader {
+	if parts.IsZero() {
+		parts = MarkdownParts{Prose: true, Headings: true}
+	}
+	return &markdownText{in: bufio.NewReader(r), parts: parts}
+}
+
+// абзац копится до конца: подчёркивание (setext) может сделать его заголовком
+type markdownText struct {
+	in    *bufio.Reader
+	parts MarkdownParts
+
+	line        []byte
+	para        []byte
+	fence       []byte // ``` или ~~~ открытого блока кода, nil вне блока
+	fenceIndent int
+	fenceQuotes int
+	lines       int
+	frontMatter bool
+	blank       bool
+	inList      bool // строки с отступом продолжают пункт списка, а не код
+
+	out []byte
+	err error
+}
+
+func (t *markdownText) Read(p []byte) (int, error) {
+	for len(t.out) == 0 && t.err == nil {
+		t.err = t.step()
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	if n > 0 {
+		return n, nil
+	}
+	return 0, t.err
+}
+
+func (t *markdownText) step() error {
+	t.out = t.out[:0]
+	line, err := t.readLine()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == nil || len(line) > 0 {
+		t.block(line)
+	}
+	if err == io.EOF {
+		t.flushPara(false)
+	}
+	return err
+}
+
+func (t *markdownText) readLine() ([]byte, error) {
+	t.line = t.line[:0]
+	for {
+		chunk, err := t.in.ReadSlice('\n')
+		t.line = append(t.line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return bytes.TrimRight(t.line, "\r\n"), err
+		}
+	}
+}
+
+func (t *markdownText) block(line []byte) {
+	first := t.lines == 0
+	t.lines++
+	if t.frontMatter {
+		if s := string(bytes.TrimRight(line, " \t")); s == "---" || s == "..." {
+			t.frontMatter = false
+		}
+		return
+	}
+	if first && string(bytes.TrimRight(line, " \t")) == "---" {
+		t.frontMatter = true
+		return
+	}
+
+	if t.fence != nil {
+		line, _ = stripQuotes(line, t.fenceQuotes)
+		if _, rest := indentOf(line); closesFence(rest, t.fence) {
+			t.fence = nil
+			return
+		}
+		t.codeLine(dropIndent(line, t.fenceIndent))
+		return
+	}
+
+	line, quotes := stripQuotes(line, -1)
+	indent, rest := indentOf(line)
+	if len(rest) == 0 {
+		t.flushPara(false)
+		t.blank = true
+		return
+	}
+	wasBlank := t.blank
+	t.blank = false
+
+	if indent >= 4 && len(t.para) == 0 && !t.inList {
+		t.codeLine(dropIndent(line, 4))
+		return
+	}
+	if fence := openingFence(rest); fence != nil {
+		t.flushPara(false)
+		t.fence, t.fenceIndent, t.fenceQuotes = fence, indent, quotes
+		return
+	}
+	if text, ok := atxHeading(rest); ok {
+		t.flushPara(false)
+		t.inline(text, t.parts.Headings)
+		t.text('\n', t.parts.Headings)
+		return
+	}
+	if len(t.para) > 0 && isSetextUnderline(rest) {
+		t.flushPara(true)
+		return
+	}
+	if isThematicBreak(rest) {
+		t.flushPara(false)
+		return
+	}
+	if len(t.para) == 0 && linkDefinition.Match(rest) {
+		return
+	}
+	if bytes.IndexByte(rest, '|') >= 0 && tableDelimiter.Match(rest) {
+		return
+	}
+
+	if n := listMarker(rest); n > 0 {
+		t.flushPara(false)
+		t.inList = true
+		rest = bytes.TrimLeft(rest[n:], " \t")
+		for _, box := range []string{"[ ] ", "[x] ", "[X] "} {
+			rest = bytes.TrimPrefix(rest, []byte(box))
+		}
+	} else if indent == 0 && (wasBlank || len(t.para) == 0) {
+		t.inList = false
+	}
+	t.para = append(t.para, rest...)
+	t.para = append(t.para, '\n')
+}
+
+func (t *markdownText) flushPara(heading bool) {
+	if len(t.para) == 0 {
+		return
+	}
+	keep := t.parts.Prose
+	if heading {
+		keep = t.parts.Headings
+	}
+	t.inline(t.para, keep)
+	t.para = t.para[:0]
+}
+
+func (t *markdownText) text(c byte, keep bool) {
+	if keep {
+		t.out = append(t.out, c)
+	}
+}
+
+func (t *markdownText) codeLine(line []byte) {
+	if t.parts.Code {
+		t.out = append(t.out, line...)
+		t.out = append(t.out, '\n')
+	}
+}
+
+func (t *markdownText) inline(s []byte, keep bool) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (isASCIIPunct(s[i+1]) || s[i+1] == '\n'):
+			t.text(s[i+1], keep)
+			i += 2
+		case c == '`':
+			n := runLen(s[i:], '`')
+			end := closingBackticks(s[i+n:], n)
+			if end < 0 {
+				for range n {
+					t.text('`', keep)
+				}
+				i += n
+				continue
+			}
+			if t.parts.Code {
+				t.out = append(t.out, s[i+n:i+n+end]...)
+				t.out = append(t.out, ' ')
+			}
+			t.text(' ', keep)
+			i += n + end + n
+		case c == '[' || c == '!' && i+1 < len(s) && s[i+1] == '[':
+			start := i
+			if c == '!' {
+				start++
+			}
+			label, next, ok := linkAt(s, start)
+			if !ok {
+				t.text(c, keep)
+				i++
+				continue
+			}
+			t.inline(label, keep)
+			i = next
+		case c == '<':
+			if m := autolink.Find(s[i:]); m != nil {
+				t.text(' ', keep)
+				i += len(m)
+			} else if m := htmlTag.FindSubmatch(s[i:]); m != nil {
+				if !htmlInline[strings.ToLower(string(m[1]))] {
+					t.text(' ', keep)
+				}
+				i += len(m[0])
+			} else if end := bytes.Index(s[i:], []byte("-->")); bytes.HasPrefix(s[i:], []byte("<!--")) && end >= 0 {
+				t.text(' ', keep)
+				i += end + 3
+			} else {
+				t.text(c, keep)
+				i++
+			}
+		case c == '&':
+			if end := bytes.IndexByte(s[i:min(len(s), i+32)], ';'); end > 1 {
+				if e, ok := lookupEntity(string(s[i+1:i+end]), true); ok {
+					if keep {
+						t.out = append(t.out, e...)
+					}
+					i += end + 1
+					continue
+				}
+			}
+			t.text(c, keep)
+			i++
+		case c == '*' || c == '_' || c == '~':
+			n := runLen(s[i:], c)
+			// _ внутри слова (snake_case) и одиночная ~ (~5) - обычный текст
+			if c == '_' && i > 0 && isWordByte(s[i-1]) && i+n < len(s) && isWordByte(s[i+n]) || c == '~' && n == 1 {
+				for range n {
+					t.text(c, keep)
+				}
+			}
+			i += n
+		case c == '|':
+			t.text(' ', keep)
+			i++
+		default:
+			t.text(c, keep)
+			i++
+		}
+	}
+}
+
+// текст в [] без адреса - shortcut-ссылка: скобки убираются
+func linkAt(s []byte, i int) (label []byte, next int, ok bool) {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth > 0 {
+				continue
+			}
+			label, j = s[i+1:j], j+1
+			if j < len(s) && s[j] == '(' {
+				if end := closingParen(s[j:]); end >= 0 {
+					return label, j + end + 1, true
+				}
+			} else if j < len(s) && s[j] == '[' {
+				if end := bytes.IndexByte(s[j:], ']'); end >= 0 {
+					return label, j + end + 1, true
+				}
+			}
+			return label, j, true
+		}
+	}
+	return nil, 0, false
+}
+
+func closingParen(s []byte) int {
+	depth := 0
+	for j := 0; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return j
+			}
+		case '\n':
+			if j+1 < len(s) && s[j+1] == '\n' {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func closingBackticks(s []byte, n int) int {
+	for j := 0; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		m := runLen(s[j:], '`')
+		if m == n {
+			return j
+		}
+		j += m
+	}
+	return -1
+}
+
+func runLen(s []byte, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// stripQuotes снимает до limit маркеров '>' (все при limit < 0)
+func stripQuotes(line []byte, limit int) ([]byte, int) {
+	depth := 0
+	for limit < 0 || depth < limit {
+		rest := bytes.TrimLeft(line, " ")
+		if len(line)-len(rest) > 3 || len(rest) == 0 || rest[0] != '>' {
+			break
+		}
+		line = bytes.TrimPrefix(rest[1:], []byte(" "))
+		depth++
+	}
+	return line, depth
+}
+
+// отступ в колонках (табуляция - до кратного 4)
+func indentOf(line []byte) (int, []byte) {
+	col := 0
+	for i, c := range line {
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col, line[i:]
+		}
+	}
+	return col, nil
+}
+
+func dropIndent(line []byte, n int) []byte {
+	col := 0
+	for i, c := range line {
+		switch {
+		case col >= n:
+			return line[i:]
+		case c == ' ':
+			col++
+		case c == '\t':
+			col += 4 - col%4
+		default:
+			return line[i:]
+		}
+	}
+	return nil
+}
+
+func openingFence(s []byte) []byte {
+	if len(s) == 0 || s[0] != '`' && s[0] != '~' {
+		return nil
+	}
+	n := runLen(s, s[0])
+	if n < 3 || s[0] == '`' && bytes.IndexByte(s[n:], '`') >= 0 {
+		return nil
+	}
+	return s[:n:n]
+}
+
+func closesFence(s, fence []byte) bool {
+	n := runLen(s, fence[0])
+	return n >= len(fence) && len(bytes.TrimSpace(s[n:])) == 0
+}
+
+func atxHeading(s []byte) ([]byte, bool) {
+	n := runLen(s, '#')
+	if n == 0 || n > 6 || n < len(s) && s[n] != ' ' && s[n] != '\t' {
+		return nil, false
+	}
+	text := bytes.TrimSpace(s[n:])
+	if i := len(bytes.TrimRight(text, "#")); i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+		text = bytes.TrimSpace(text[:i])
+	}
+	return text, true
+}
+
+func isSetextUnderline(s []byte) bool {
+	s = bytes.TrimRight(s, " \t")
+	return (s[0] == '=' || s[0] == '-') && runLen(s, s[0]) == len(s)
+}
+
+func isThematicBreak(s []byte) bool {
+	c := s[0]
+	if c != '-' && c != '*' && c != '_' {
+		return false
+	}
+	n := 0
+	for _, b := range s {
+		switch b {
+		case c:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// длина маркера списка ("-", "1.", "2)") или 0
+func listMarker(s []byte) int {
+	i := 0
+	if s[0] == '-' || s[0] == '*' || s[0] == '+' {
+		i = 1
+	} else {
+		for i < len(s) && i < 9 && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) || s[i] != '.' && s[i] != ')' {
+			return 0
+		}
+		i++
+	}
+	if i < len(s) && s[i] != ' ' && s[i] != '\t' {
+		return 0
+	}
+	return i
+}
+
+func isASCIIPunct(c byte) bool {
+	return '!' <= c && c <= '/' || ':' <= c && c <= '@' || '[' <= c && c <= '`' || '{' <= c && c <= '~'
+}
+
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,130 @@
+package wordstat
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const markdownDoc = "---\n" +
+	"title: Front matter\n" +
+	"---\n" +
+	"# Install *the* tool #\n" +
+	"\n" +
+	"Run `go install` from the [project page](https://example.com/x_(y) \"Title\"),\n" +
+	"see ![the logo](logo.png) and <https://go.dev> or <b>bold</b>&nbsp;text &amp; snake_case __strong__ ~~gone~~.\n" +
+	"\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n" +
+	"\n" +
+	"    indented code\n" +
+	"\n" +
+	"Setext heading\n" +
+	"--------------\n" +
+	"\n" +
+	"> - [x] quoted item with a [reference][ref]\n" +
+	"> 1. second \\*literal\\*\n" +
+	"\n" +
+	"| Name | Value |\n" +
+	"|------|:-----:|\n" +
+	"| a    | b     |\n" +
+	"\n" +
+	"***\n" +
+	"[ref]: https://example.com/ref\n"
+
+func TestNewMarkdownTextReader(t *testing.T) {
+	tests := []struct {
+		parts string
+		want  string
+	}{
+		{"", "Install the tool Run from the project page, see the logo and or bold text & snake_case strong gone. " +
+			"Setext heading quoted item with a reference second *literal* Name Value a b"},
+		{"prose", "Run from the project page, see the logo and or bold text & snake_case strong gone. " +
+			"quoted item with a reference second *literal* Name Value a b"},
+		{"headings", "Install the tool Setext heading"},
+		{"code", "go install func main() {} indented code"},
+	}
+	for _, tt := range tests {
+		parts, err := ParseMarkdownParts(tt.parts)
+		if err != nil {
+			t.Fatalf("ParseMarkdownParts(%q) error = %v", tt.parts, err)
+		}
+		if got := extract(t, NewMarkdownTextReader(strings.NewReader(markdownDoc), parts)); got != tt.want {
+			t.Errorf("parts=%q:\ngot  %q\nwant %q", tt.parts, got, tt.want)
+		}
+		if got := extract(t, NewMarkdownTextReader(iotest.OneByteReader(strings.NewReader(markdownDoc)), parts)); got != tt.want {
+			t.Errorf("parts=%q, one byte reader: got %q", tt.parts, got)
+		}
+	}
+
+	for in, want := range map[string]string{
+		"```\nunterminated fence\n":         "",
+		"- item\n\n    continued\n":         "item continued",
+		"a `b\n":                            "a `b",
+		"``a ` b`` c\n":                     "c",
+		"[not a link\n":                     "[not a link",
+		"> ```\n> code\n> ```\n> after\n":   "after",
+		"~5 min, 2 * 3\n":                   "~5 min, 2 3",
+		"#hashtag\n":                        "#hashtag",
+		"a\r\nb\r\n":                        "a b",
+		strings.Repeat("w", 10000) + "\n":   strings.Repeat("w", 10000),
+		"x <!-- note\nmore --> y\n":         "x y",
+		"Line one\\\nline two  \nthree\n":   "Line one line two three",
+		"para\n    not code\n":              "para not code",
+		"<mail@example.com> [t]: no def\n":  "t: no def",
+		"1986\\. A great year\n":            "1986. A great year",
+		"![img](a.png)[link](b.md \"x\")\n": "imglink",
+	} {
+		if got := extract(t, NewMarkdownTextReader(strings.NewReader(in), MarkdownParts{})); got != want {
+			t.Errorf("%.40q: got %.40q, want %.40q", in, got, want)
+		}
+	}
+
+	if _, err := ParseMarkdownParts("prose,tables"); err == nil {
+		t.Fatalf("ParseMarkdownParts(tables): no error")
+	}
+}
+
+func TestRun_InputMarkdown(t *testing.T) {
+	var out bytes.Buffer
+	opts := Options{SortBy: "count", Input: "markdown", Tokenizer: "unicode", K: 2}
+	if err := Run(strings.NewReader("# Word\n\nword `code` [other](http://code.example)\n\n```\ncode code\n```\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "word 2\nother 1\n"; out.String() != want {
+		t.Fatalf("got %q want %q", out.String(), want)
+	}
+
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Markdown: MarkdownParts{Code: true}}); err == nil {
+		t.Fatalf("Markdown parts without Input=markdown: no error")
+	}
+}
+
+func TestHTTPWordstat_Markdown(t *testing.T) {
+	h := NewHTTPMux()
+	for target, want := range map[string]string{
+		"/wordstat":                      "a 1\nb 1\n",
+		"/wordstat?mdparts=code":         "c 1\n",
+		"/wordstat?mdparts=headings":     "a 1\n",
+		"/wordstat?input=text":           "# 1\n`c` 1\na 1\nb 1\n",
+		"/wordstat?mdparts=prose,tables": "",
+	} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("# a\n\nb `c`\n"))
+		req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if want == "" {
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("%s: status=%d want 400", target, rr.Code)
+			}
+			continue
+		}
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Fatalf("%s: status=%d body=%q want %q", target, rr.Code, rr.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"iter"
+	"strconv"
+	"unicode/utf8"
+)
+
+var xmlEntities = map[string]string{"amp": "&", "lt": "<", "gt": ">", "quot": `"`, "apos": "'"}
+
+// теги этих элементов не разделяют слова: "<b>W</b>ord" - одно слово
+var htmlInline = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true, "code": true,
+	"data": true, "dfn": true, "em": true, "font": true, "i": true, "kbd": true, "mark": true,
+	"q": true, "s": true, "samp": true, "small": true, "span": true, "strong": true, "sub": true,
+	"sup": true, "time": true, "tt": true, "u": true, "var": true, "wbr": true,
+}
+
+// NewHTMLTextReader отдаёт видимый текст HTML: без тегов, комментариев, <script> и <style>.
+func NewHTMLTextReader(r io.Reader) io.Reader {
+	return &markupText{in: bufio.NewReader(r), html: true}
+}
+
+func NewXMLTextReader(r io.Reader) io.Reader {
+	return &markupText{in: bufio.NewReader(r)}
+}
+
+func inputDocuments(docs iter.Seq2[Document, error], opts Options) iter.Seq2[Document, error] {
+	switch opts.Input {
+	case "html":
+		return MapDocuments(docs, func(r io.Reader) (io.Reader, error) { return NewHTMLTextReader(r), nil })
+	case "xml":
+		return MapDocuments(docs, func(r io.Reader) (io.Reader, error) { return NewXMLTextReader(r), nil })
+	case "markdown":
+		return MapDocuments(docs, func(r io.Reader) (io.Reader, error) { return NewMarkdownTextReader(r, opts.Markdown), nil })
+	default:
+		return docs
+	}
+}
+
+type markupText struct {
+	in   *bufio.Reader
+	html bool
+	out  []byte
+	err  error
+}
+
+func (t *markupText) Read(p []byte) (int, error) {
+	for len(t.out) == 0 && t.err == nil {
+		t.err = t.step()
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	if n > 0 {
+		return n, nil
+	}
+	return 0, t.err
+}
+
+func (t *markupText) step() error {
+	chunk, err := t.in.Peek(max(t.in.Buffered(), 1))
+	if len(chunk) == 0 {
+		return err
+	}
+	if i := bytes.IndexAny(chunk, "<&"); i != 0 {
+		if i < 0 {
+			i = len(chunk)
+		}
+		t.out = append(t.out[:0], chunk[:i]...)
+		_, _ = t.in.Discard(i)
+		return nil
+	}
+	_, _ = t.in.Discard(1)
+	t.out = t.out[:0]
+	if chunk[0] == '&' {
+		return t.entity()
+	}
+	return t.markup()
+}
+
+// неизвестная или незакрытая сущность остаётся текстом
+func (t *markupText) entity() error {
+	peek, err := t.in.Peek(32)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	end := bytes.IndexByte(peek, ';')
+	if end <= 0 {
+		t.out = append(t.out, '&')
+		return nil
+	}
+	s, ok := lookupEntity(string(peek[:end]), t.html)
+	if !ok {
+		t.out = append(t.out, '&')
+		return nil
+	}
+	t.out = append(t.out, s...)
+	_, _ = t.in.Discard(end + 1)
+	return nil
+}
+
+func lookupEntity(name string, html bool) (string, bool) {
+	var s string
+	var ok bool
+	if name[0] == '#' {
+		var n uint64
+		var err error
+		if len(name) > 1 && (name[1] == 'x' || name[1] == 'X') {
+			n, err = strconv.ParseUint(name[2:], 16, 32)
+		} else {
+			n, err = strconv.ParseUint(name[1:], 10, 32)
+		}
+		if ok = err == nil && utf8.ValidRune(rune(n)); ok {
+			s = string(rune(n))
+		}
+	} else if s, ok = xmlEntities[name]; !ok && html {
+		s, ok = xml.HTMLEntity[name]
+	}
+	if s == "\u00a0" { // &nbsp; разделяет слова
+		s = " "
+	}
+	return s, ok
+}
+
+func (t *markupText) markup() error {
+	peek, err := t.in.Peek(9)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch {
+	case bytes.HasPrefix(peek, []byte("!--")):
+		_, _ = t.in.Discard(3)
+		return t.skipPast("-->")
+	case bytes.HasPrefix(peek, []byte("![CDATA[")):
+		_, _ = t.in.Discard(8)
+		return t.copyUntil("]]>")
+	case len(peek) > 0 && (peek[0] == '!' || peek[0] == '?'):
+		return t.skipPast(">")
+	case len(peek) > 1 && peek[0] == '/' && isNameStart(peek[1]):
+		_, _ = t.in.Discard(1)
+		name, _, err := t.tag()
+		t.separate(name)
+		return err
+	case len(peek) > 0 && isNameStart(peek[0]):
+		name, selfClosing, err := t.tag()
+		if err != nil {
+			return err
+		}
+		t.separate(name)
+		if t.html && !selfClosing && (name == "script" || name == "style") {
+			return t.skipRawText(name)
+		}
+		return nil
+	default:
+		t.out = append(t.out, '<')
+		return nil
+	}
+}
+
+func (t *markupText) separate(name string) {
+	if !t.html || !htmlInline[name] {
+		t.out = append(t.out, ' ')
+	}
+}
+
+// в значениях атрибутов в кавычках может быть '>'
+func (t *markupText) tag() (name string, selfClosing bool, err error) {
+	var nb []byte
+	for {
+		c, err := t.in.ReadByte()
+		if err != nil {
+			return string(nb), false, eofOK(err)
+		}
+		if isSpace(c) || c == '/' || c == '>' {
+			_ = t.in.UnreadByte()
+			break
+		}
+		if t.html && 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		nb = append(nb, c)
+	}
+
+	var quote, prev byte
+	for {
+		c, err := t.in.ReadByte()
+		if err != nil {
+			return string(nb), false, eofOK(err)
+		}
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && prev == '=':
+			quote = c
+		case c == '>':
+			return string(nb), prev == '/', nil
+		}
+		if !isSpace(c) {
+			prev = c
+		}
+	}
+}
+
+func (t *markupText) skipRawText(name string) error {
+	for {
+		_, err := t.in.ReadSlice('<')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return eofOK(err)
+		}
+		peek, err := t.in.Peek(len(name) + 2)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(peek) < len(name)+2 || peek[0] != '/' || !bytes.EqualFold(peek[1:len(name)+1], []byte(name)) {
+			continue
+		}
+		if c := peek[len(name)+1]; isSpace(c) || c == '>' || c == '/' {
+			return t.skipPast(">")
+		}
+	}
+}
+
+func (t *markupText) skipPast(end string) error {
+	return t.scanPast(end, false)
+}
+
+func (t *markupText) copyUntil(end string) error {
+	return t.scanPast(end, true)
+}
+
+func (t *markupText) scanPast(end string, keep bool) error {
+	tail := make([]byte, 0, len(end))
+	for {
+		c, err := t.in.ReadByte()
+		if err != nil {
+			return eofOK(err)
+		}
+		if keep {
+			t.out = append(t.out, c)
+		}
+		if len(tail) == len(end) {
+			tail = append(tail[:0], tail[1:]...)
+		}
+		tail = append(tail, c)
+		if string(tail) == end {
+			if keep {
+				t.out = t.out[:len(t.out)-len(end)]
+			}
+			return nil
+		}
+	}
+}
+
+// незакрытая конструкция в конце документа - не ошибка
+func eofOK(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func isNameStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == ':' || c >= 0x80
+}
@@ -0,0 +1,112 @@
+package wordstat
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const htmlPage = `<!DOCTYPE html>
+<html><head><title>Saved&nbsp;page</title>
+<style>body { color: red } /* </styles> */</style>
+<script type="text/javascript">if (a<b && c>d) { document.write("<div>hidden</div>"); }</script>
+<SCRIPT>var x = "</scriptx>";</SCRIPT>
+</head>
+<body class="main">
+<!-- a comment with <tags> -- and dashes --->
+<div id="x" data-title='a > b'>Caf&eacute; &amp; bar&#33; &#x263A;</div>
+<p>Bold<b>er</b> and<br>next<br/>line</p>
+<p>1 < 2, AT&T</p>
+</body></html>`
+
+func extract(t *testing.T, r io.Reader) string {
+	t.Helper()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	return strings.Join(strings.Fields(string(b)), " ")
+}
+
+func TestNewHTMLTextReader(t *testing.T) {
+	want := "Saved page Café & bar! ☺ Bolder and next line 1 < 2, AT&T"
+	if got := extract(t, NewHTMLTextReader(strings.NewReader(htmlPage))); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+	// one byte at a time: constructs span reads
+	if got := extract(t, NewHTMLTextReader(iotest.OneByteReader(strings.NewReader(htmlPage)))); got != want {
+		t.Fatalf("one byte reader: got %q", got)
+	}
+
+	for in, want := range map[string]string{
+		"text <!-- unterminated":         "text",
+		"a <script>b":                    "a",
+		"a <div":                         "a",
+		"&unknown; &amp":                 "&unknown; &amp",
+		"&nbsp;x&#xFFFFFFFF;":            "x&#xFFFFFFFF;",
+		strings.Repeat("<p>w</p>", 2000): strings.TrimSpace(strings.Repeat("w ", 2000)),
+		"<script>" + strings.Repeat("x", 10000) + "</script >y": "y",
+	} {
+		if got := extract(t, NewHTMLTextReader(strings.NewReader(in))); got != want {
+			t.Errorf("%.40q: got %.40q, want %.40q", in, got, want)
+		}
+	}
+}
+
+func TestNewXMLTextReader(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<!DOCTYPE note>
+<note lang="en"><to>Tove</to><from>Jani</from><b>Bo</b>ld
+<body><![CDATA[x < y & <raw>]]> &lt;tag&gt; &apos;q&apos; &nbsp;</body></note>`
+	want := "Tove Jani Bo ld x < y & <raw> <tag> 'q' &nbsp;"
+	if got := extract(t, NewXMLTextReader(strings.NewReader(doc))); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestRun_InputHTML(t *testing.T) {
+	var out bytes.Buffer
+	opts := Options{SortBy: "count", Input: "html", Tokenizer: "unicode", K: 2}
+	if err := Run(strings.NewReader(`<p class="x">word <i>word</i> other</p><script>word()</script>`), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "word 2\nother 1\n"; out.String() != want {
+		t.Fatalf("got %q want %q", out.String(), want)
+	}
+
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Input: "pdf"}); err == nil {
+		t.Fatalf("Input=pdf: no error")
+	}
+}
+
+func TestHTTPWordstat_HTML(t *testing.T) {
+	h := NewHTTPMux()
+	for ct, want := range map[string]string{
+		"text/html; charset=utf-8": "a 2\nb 1\n",
+		"application/xml":          "a 2\nb 1\n",
+		"text/plain":               "<a>a</a> 1\n<p>a 1\nb</p> 1\n",
+	} {
+		body := "<p>a <a>a</a> b</p>"
+		if strings.HasSuffix(ct, "xml") {
+			body = "<r><p>a</p> <p>a</p> b</r>"
+		}
+		req := httptest.NewRequest(http.MethodPost, "/wordstat", strings.NewReader(body))
+		req.Header.Set("Content-Type", ct)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Fatalf("%s: status=%d body=%q want %q", ct, rr.Code, rr.Body.String(), want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?input=html", strings.NewReader("<b>x</b>"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Body.String() != "x 1\n" {
+		t.Fatalf("input=html: body=%q", rr.Body.String())
+	}
+}
@@ -0,0 +1,101 @@
+package wordstat
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ngramSep разделяет токены n-граммы в ключах счётчиков; в отчёте - пробел.
+const ngramSep = "\x1f"
+
+// окно - на документ, n-граммы не переходят между документами
+type ngramWindow struct {
+	n    int
+	lens []int // длины токенов в buf
+	buf  []byte
+	tok  []byte
+}
+
+func newNgramWindow(n int) *ngramWindow {
+	if n < 1 {
+		n = 1
+	}
+	return &ngramWindow{n: n}
+}
+
+// push возвращает n-грамму, которая кончается на tok (nil, пока токенов меньше n); слайс живёт до следующего push
+func (g *ngramWindow) push(tok []byte) []byte {
+	if len(tok) == 0 {
+		return nil
+	}
+	if g.n == 1 {
+		return tok
+	}
+	// сам байт-разделитель внутри токена заменяем пробелом, чтобы ключ
+	// однозначно делился на токены
+	if bytes.IndexByte(tok, ngramSep[0]) >= 0 {
+		g.tok = append(g.tok[:0], tok...)
+		for i, c := range g.tok {
+			if c == ngramSep[0] {
+				g.tok[i] = ' '
+			}
+		}
+		tok = g.tok
+	}
+	if len(g.lens) == g.n {
+		drop := g.lens[0] + len(ngramSep)
+		g.buf = g.buf[:copy(g.buf, g.buf[drop:])]
+		g.lens = append(g.lens[:0], g.lens[1:]...)
+	}
+	if len(g.lens) > 0 {
+		g.buf = append(g.buf, ngramSep...)
+	}
+	g.buf = append(g.buf, tok...)
+	g.lens = append(g.lens, len(tok))
+	if len(g.lens) < g.n {
+		return nil
+	}
+	return g.buf
+}
+
+func (g *ngramWindow) last(k int) [][]byte {
+	toks := make([][]byte, 0, len(g.lens))
+	off := 0
+	for _, l := range g.lens {
+		toks = append(toks, bytes.Clone(g.buf[off:off+l]))
+		off += l + len(ngramSep)
+	}
+	return toks[max(0, len(toks)-k):]
+}
+
+func splitNgram(key string) (phrase string, tokens []string) {
+	if !strings.Contains(key, ngramSep) {
+		return key, nil
+	}
+	tokens = strings.Split(key, ngramSep)
+	return strings.Join(tokens, " "), tokens
+}
+
+func expandNgrams(entries []Entry) {
+	for i := range entries {
+		e := &entries[i]
+		e.Word, e.Tokens = splitNgram(e.Word)
+		e.Form, _ = splitNgram(e.Form)
+	}
+}
+
+func stemNgram(stem func(string) string, n int) func(string) string {
+	if n <= 1 {
+		return stem
+	}
+	return func(key string) string {
+		if !strings.Contains(key, ngramSep) {
+			return stem(key)
+		}
+		parts := strings.Split(key, ngramSep)
+		for i, p := range parts {
+			parts[i] = stem(p)
+		}
+		return strings.Join(parts, ngramSep)
+	}
+}
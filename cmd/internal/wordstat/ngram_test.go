@@ -0,0 +1,121 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNgramWindow(t *testing.T) {
+	win := newNgramWindow(3)
+	var got []string
+	for _, tok := range []string{"a", "bb", "c", "dd", "e"} {
+		if key := win.push([]byte(tok)); key != nil {
+			got = append(got, strings.ReplaceAll(string(key), ngramSep, " "))
+		}
+	}
+	want := []string{"a bb c", "bb c dd", "c dd e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%q want=%q", got, want)
+	}
+}
+
+func TestRun_TokenWithNgramSep(t *testing.T) {
+	// 0x1f внутри токена не должен делить его на n-грамму
+	tests := []struct {
+		ngram int
+		want  string
+	}{
+		{1, "a\x1fb 2\nc 1\nx\x1fb 1\n"},
+		{2, "a b c 1\nc a b 1\nx b a b 1\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		opts := Options{SortBy: "word", Ngram: tt.ngram, Stopwords: Stopwords{"b": {}}}
+		if err := Run(strings.NewReader("x\x1fb a\x1fb c a\x1fb"), &out, opts); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Fatalf("ngram=%d: got %q want %q", tt.ngram, out.String(), tt.want)
+		}
+	}
+}
+
+func TestCountNgrams_AllEngines(t *testing.T) {
+	input := "a b c a b c a b"
+	want := map[string]int{
+		"a" + ngramSep + "b": 3,
+		"b" + ngramSep + "c": 2,
+		"c" + ngramSep + "a": 2,
+	}
+	ctx := context.Background()
+
+	engines := map[string]func() (map[string]int, error){
+		"bufio": func() (map[string]int, error) {
+			return counted(func(c Counter) error {
+				return countBufio(ctx, bufio.NewReader(strings.NewReader(input)), WhitespaceTokenizer, 2, c)
+			})
+		},
+		"bytes": func() (map[string]int, error) {
+			return counted(func(c Counter) error { return countBytes(ctx, []byte(input), WhitespaceTokenizer, 2, c) })
+		},
+		// blocks of two bytes: windows must still cross block boundaries
+		"concurrent": func() (map[string]int, error) {
+			return counted(func(c Counter) error {
+				return countBufioConcurrent(ctx, bufio.NewReader(strings.NewReader(input)), 3, 2, WhitespaceTokenizer, 2, c)
+			})
+		},
+	}
+	for name, count := range engines {
+		got, err := count()
+		if err != nil {
+			t.Fatalf("%s: error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got=%q want=%q", name, got, want)
+		}
+	}
+}
+
+func TestRunDocs_NgramsDoNotSpanDocuments(t *testing.T) {
+	docs := func(yield func(Document, error) bool) {
+		_ = yield(Document{Name: "1", R: strings.NewReader("new york")}, nil) &&
+			yield(Document{Name: "2", R: strings.NewReader("city new york")}, nil)
+	}
+	var out strings.Builder
+
+	opts := Options{SortBy: "count", Ngram: 2}
+	if err := RunDocsCtx(context.Background(), docs, &out, opts); err != nil {
+		t.Fatalf("RunDocsCtx() error = %v", err)
+	}
+	want := "new york 2\ncity new 1\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestRun_NgramJSON(t *testing.T) {
+	en, _ := BuiltinStopwords("en")
+	in := strings.NewReader("the state of the art is the state of the art")
+	var out strings.Builder
+
+	opts := Options{SortBy: "count", Format: "json", Min: 2, Ngram: 4, Stopwords: en}
+	if err := Run(in, &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got []Entry
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v, out=%q", err, out.String())
+	}
+	want := []Entry{
+		{Word: "state of the art", Count: 2, Tokens: []string{"state", "of", "the", "art"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%v want=%v", got, want)
+	}
+}
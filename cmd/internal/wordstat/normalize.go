@@ -0,0 +1,117 @@
+package wordstat
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer: нулевое значение срезает BOM и приводит к нижнему регистру, как AppendNormalizedWord.
+type Normalizer struct {
+	Form     string // "" | "nfc" | "nfkc"
+	CaseFold string // "lower" (default) | "fold" | "none"
+	MergeYo  bool   // ё -> е
+}
+
+func (n Normalizer) Validate() error {
+	switch n.Form {
+	case "", "nfc", "nfkc":
+	default:
+		return fmt.Errorf("invalid -normalize=%q (use nfc|nfkc)", n.Form)
+	}
+	switch n.CaseFold {
+	case "", "lower", "fold", "none":
+	default:
+		return fmt.Errorf("invalid -casefold=%q (use lower|fold|none)", n.CaseFold)
+	}
+	return nil
+}
+
+func (n Normalizer) isDefault() bool {
+	return n.Form == "" && (n.CaseFold == "" || n.CaseFold == "lower") && !n.MergeYo
+}
+
+func (n Normalizer) AppendNormalized(dst, tok []byte) []byte {
+	tok = bytes.TrimPrefix(tok, utf8BOM)
+
+	ascii := true
+	for _, c := range tok {
+		if c >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		if n.CaseFold == "none" {
+			return append(dst, tok...)
+		}
+		// на ASCII lower и fold совпадают
+		return AppendNormalizedWord(dst, tok)
+	}
+
+	b := tok
+	form, hasForm := n.form()
+	if hasForm {
+		b = form.Bytes(b)
+	}
+	switch n.CaseFold {
+	case "none":
+	case "fold":
+		b = cases.Fold().Bytes(b)
+	default:
+		b = AppendNormalizedWord(nil, b)
+	}
+	// после смены регистра строка может снова быть не нормализована
+	if hasForm && n.CaseFold != "none" {
+		b = form.Bytes(b)
+	}
+	if n.MergeYo {
+		for _, p := range yoPairs {
+			b = bytes.ReplaceAll(b, []byte(p[0]), []byte(p[1]))
+		}
+	}
+	return append(dst, b...)
+}
+
+// ё и е + U+0308 -> е
+var yoPairs = [][2]string{
+	{"ё", "е"}, {"Ё", "Е"},
+	{"е\u0308", "е"}, {"Е\u0308", "Е"},
+}
+
+func (n Normalizer) form() (norm.Form, bool) {
+	switch n.Form {
+	case "nfc":
+		return norm.NFC, true
+	case "nfkc":
+		return norm.NFKC, true
+	default:
+		return 0, false
+	}
+}
+
+type normalizedTokenizer struct {
+	Tokenizer
+	n Normalizer
+}
+
+func (t normalizedTokenizer) AppendNormalized(dst, tok []byte) []byte {
+	return t.n.AppendNormalized(dst, tok)
+}
+
+func WithNormalizer(t Tokenizer, n Normalizer) Tokenizer {
+	return normalizedTokenizer{Tokenizer: t, n: n}
+}
+
+func (sw Stopwords) normalized(n Normalizer) Stopwords {
+	out := make(Stopwords, len(sw))
+	for w := range sw {
+		if w := n.AppendNormalized(nil, []byte(w)); len(w) > 0 {
+			out[string(w)] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package wordstat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		n    Normalizer
+		in   string
+		want string
+	}{
+		{Normalizer{}, "Caf\u00e9", "caf\u00e9"},
+		{Normalizer{Form: "nfc"}, "Cafe\u0301", "caf\u00e9"},
+		{Normalizer{Form: "nfkc"}, "ＷＯＲＤ", "word"},
+		{Normalizer{CaseFold: "fold"}, "Straße", "strasse"},
+		{Normalizer{CaseFold: "fold"}, "STRASSE", "strasse"},
+		{Normalizer{CaseFold: "none"}, "Hello", "Hello"},
+		{Normalizer{CaseFold: "none"}, "\ufeffПривет", "Привет"},
+		{Normalizer{MergeYo: true}, "Ёлка", "елка"},
+		{Normalizer{MergeYo: true, CaseFold: "none"}, "Ёлка", "Елка"},
+		{Normalizer{MergeYo: true}, "е\u0308ж", "еж"},
+	}
+	for _, tt := range tests {
+		if got := string(tt.n.AppendNormalized(nil, []byte(tt.in))); got != tt.want {
+			t.Errorf("%+v.AppendNormalized(%q) = %q, want %q", tt.n, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRun_Normalizer_AllEngines(t *testing.T) {
+	input := "caf\u00e9 Cafe\u0301 Straße STRASSE ＷＯＲＤ word ёж еж"
+	want := "café 2\nstrasse 2\nword 2\nеж 2\n"
+
+	for _, engine := range []Options{{Workers: 1}, {Workers: 3}, {Buffered: true}} {
+		opts := engine
+		opts.SortBy = "count"
+		opts.Normalizer = Normalizer{Form: "nfkc", CaseFold: "fold", MergeYo: true}
+
+		var out strings.Builder
+		if err := Run(strings.NewReader(input), &out, opts); err != nil {
+			t.Fatalf("Run(%+v) error = %v", opts, err)
+		}
+		if out.String() != want {
+			t.Fatalf("Run(%+v) got:\n%q\nwant:\n%q", opts, out.String(), want)
+		}
+	}
+}
+
+func TestValidateOptions_Normalizer(t *testing.T) {
+	for _, n := range []Normalizer{{Form: "nfd"}, {CaseFold: "upper"}} {
+		if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Normalizer: n}); err == nil {
+			t.Fatalf("expected error for %+v", n)
+		}
+	}
+}
@@ -5,6 +5,38 @@ type Options struct {
 	Min      int
 	SortBy   string
 	Format   string // "text" | "json"
-	Workers  int
+	Workers  int    // 0 = one per CPU with Engine auto|concurrent, otherwise 1
 	Buffered bool
+
+	Engine   string             // "" (Buffered and Workers decide) | "auto" | "stream" | "buffered" | "concurrent"
+	OnEngine func(EngineChoice) // called with the engine picked for every document
+
+	Input    string        // "text" (default) | "html" | "xml" | "markdown": count only the text of markup documents
+	Markdown MarkdownParts // with Input "markdown": which parts to count
+
+	Tokenizer string // registered name: "whitespace" (default) | "unicode" | RegisterTokenizer
+	Stem      string // "" (off) | "en" | "ru"
+	Stopwords Stopwords
+	Ngram     int // count windows of N consecutive tokens (0, 1 = single words)
+
+	Unit        string // "word" (default) | "char" | "char-ngram" (N = Ngram, default 2)
+	LettersOnly bool   // with char units: count letters only
+
+	Normalizer Normalizer // Unicode form, case folding and ё→е merge; zero value lower-cases
+
+	Pattern string // count regexp matches per line instead of tokenizer tokens
+	Group   int    // with Pattern: capture group to count (0 = whole match)
+
+	Approx    bool // bounded-memory top-K with a Space-Saving summary; counts are estimates
+	ApproxCap int  // keys the summary monitors (0 = max(1024, 10*K))
+
+	MaxMem int64 // exact counting within about this many bytes, spilling sorted runs to temp files (0 = no limit)
+
+	Sketch  bool     // HyperLogLog + Count-Min summary instead of a word list
+	Queries []string // with Sketch: words (or phrases) whose counts are reported
+
+	ByDocument bool // report every document separately, then the total
+
+	Classes ClassFilter // keep or drop token classes; implies the "typed" tokenizer
+	ByClass bool        // group the report by class, K applies to every class
 }
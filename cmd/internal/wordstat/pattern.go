@@ -0,0 +1,69 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+func compilePattern(pattern string, group int) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -pattern: %w", err)
+	}
+	if group < 0 || group > re.NumSubexp() {
+		return nil, fmt.Errorf("invalid -group=%d (pattern has %d groups)", group, re.NumSubexp())
+	}
+	return re, nil
+}
+
+// построчно: ^ и $ - границы строки, совпадения не переходят через перенос
+func countPattern(ctx context.Context, in *bufio.Reader, re *regexp.Regexp, group int, t Tokenizer, ngram int, dst Counter) error {
+	return patternKeys(ctx, in, re, group, t, ngram, adderFor(dst))
+}
+
+func patternKeys(ctx context.Context, in *bufio.Reader, re *regexp.Regexp, group int, t Tokenizer, ngram int, counts keyAdder) error {
+	win := newNgramWindow(ngram)
+	var line, norm []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var err error
+		line, err = readLine(in, line[:0])
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("read line: %w", err)
+		}
+		for _, m := range re.FindAllSubmatchIndex(line, -1) {
+			start, end := m[2*group], m[2*group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			norm = t.AppendNormalized(norm[:0], line[start:end])
+			counts.add(win.push(norm))
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func readLine(in *bufio.Reader, dst []byte) ([]byte, error) {
+	for {
+		chunk, err := in.ReadSlice('\n')
+		dst = append(dst, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		dst = bytes.TrimSuffix(dst, []byte("\n"))
+		dst = bytes.TrimSuffix(dst, []byte("\r"))
+		return dst, err
+	}
+}
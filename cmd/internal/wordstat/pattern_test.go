@@ -0,0 +1,113 @@
+package wordstat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const logInput = "E1001 disk full user=alice\r\n" +
+	"ok user=bob\n" +
+	"E1001 retry E2002 user=alice\n" +
+	"E12 short user=\n" +
+	"E2002 user=carol"
+
+func TestRun_Pattern(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "whole match",
+			opts: Options{Pattern: `\bE[0-9]{4}\b`, Normalizer: Normalizer{CaseFold: "none"}},
+			want: "E1001 2\nE2002 2\n",
+		},
+		{
+			name: "capture group",
+			opts: Options{Pattern: `user=(\w*)`, Group: 1},
+			want: "alice 2\nbob 1\ncarol 1\n",
+		},
+		{
+			name: "anchored per line",
+			opts: Options{Pattern: `^\w+`, Min: 2},
+			want: "e1001 2\n",
+		},
+		{
+			name: "bigrams of matches",
+			opts: Options{Pattern: `E\d{4}`, Ngram: 2},
+			want: "e1001 e1001 1\ne1001 e2002 1\ne2002 e2002 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Options{{Workers: 1}, {Workers: 2}, {Buffered: true}} {
+			opts := tt.opts
+			opts.SortBy = "count"
+			opts.Workers = engine.Workers
+			opts.Buffered = engine.Buffered
+
+			var out strings.Builder
+			if err := Run(strings.NewReader(logInput), &out, opts); err != nil {
+				t.Fatalf("%s: Run(%+v) error = %v", tt.name, opts, err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("%s: Run(%+v) got:\n%q\nwant:\n%q", tt.name, opts, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestValidateOptions_Pattern(t *testing.T) {
+	bad := []Options{
+		{Pattern: `(`},
+		{Pattern: `a(b)`, Group: 2},
+		{Pattern: `a`, Group: -1},
+		{Pattern: `a`, Unit: "char"},
+		{Pattern: `a`, Tokenizer: "unicode"},
+	}
+	for _, opts := range bad {
+		opts.SortBy, opts.Workers = "word", 1
+		if err := ValidateOptions(opts); err == nil {
+			t.Fatalf("ValidateOptions(%+v): expected error", opts)
+		}
+	}
+}
+
+func TestRunCtx_Pattern_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := RunCtx(ctx, strings.NewReader(logInput), &strings.Builder{}, Options{SortBy: "word", Workers: 1, Pattern: `E\d+`})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v want context.Canceled", err)
+	}
+}
+
+func TestHTTPWordstat_Pattern(t *testing.T) {
+	h := NewHTTPMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/wordstat?sort=count&pattern=user%3D(%5Cw%2B)&group=1", strings.NewReader(logInput))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%q", rr.Code, rr.Body.String())
+	}
+	want := "alice 2\nbob 1\ncarol 1\n"
+	if rr.Body.String() != want {
+		t.Fatalf("got=%q want=%q", rr.Body.String(), want)
+	}
+
+	for _, q := range []string{"pattern=%28", "pattern=a&group=1", "pattern=a&group=x", "pattern=a&tokenizer=unicode"} {
+		req := httptest.NewRequest(http.MethodPost, "/wordstat?"+q, strings.NewReader("a"))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status=%d want=%d body=%q", q, rr.Code, http.StatusBadRequest, rr.Body.String())
+		}
+	}
+}
@@ -10,7 +10,18 @@ func PrintReport(w io.Writer, entries []Entry, opts Options) error {
 	switch opts.Format {
 	case "", "text":
 		for _, e := range entries {
-			if _, err := fmt.Fprintln(w, e.Word, e.Count); err != nil {
+			line := []any{e.Word, e.Count}
+			if e.Form != "" {
+				line = append(line, e.Form)
+			}
+			if e.Class != "" {
+				line = append(line, e.Class)
+			}
+			if e.Error > 0 {
+				line = append(line, fmt.Sprintf("±%d", e.Error))
+			}
+			_, err := fmt.Fprintln(w, line...)
+			if err != nil {
 				return fmt.Errorf("print report line %w", err)
 			}
 		}
@@ -25,3 +36,54 @@ func PrintReport(w io.Writer, entries []Entry, opts Options) error {
 		return fmt.Errorf("unknown format %q (use text|json)", opts.Format)
 	}
 }
+
+type DocumentReport struct {
+	Document string  `json:"document"`
+	Words    []Entry `json:"words"`
+}
+
+// текст - с заголовками "==> name <==", как у head(1); json - {"documents": [...], "total": [...]}
+func PrintDocumentsReport(w io.Writer, total []Entry, docs []DocumentReport, opts Options) error {
+	switch opts.Format {
+	case "", "text":
+		for i, d := range docs {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			if _, err := fmt.Fprintf(w, "==> %s <==\n", d.Document); err != nil {
+				return fmt.Errorf("print report line %w", err)
+			}
+			if err := PrintReport(w, d.Words, opts); err != nil {
+				return err
+			}
+		}
+		if len(docs) > 0 {
+			fmt.Fprintln(w)
+		}
+		if _, err := fmt.Fprintln(w, "==> total <=="); err != nil {
+			return fmt.Errorf("print report line %w", err)
+		}
+		return PrintReport(w, total, opts)
+	case "json":
+		rep := struct {
+			Documents []DocumentReport `json:"documents"`
+			Total     []Entry          `json:"total"`
+		}{Documents: make([]DocumentReport, len(docs)), Total: nonNil(total)}
+		for i, d := range docs {
+			rep.Documents[i] = DocumentReport{Document: d.Document, Words: nonNil(d.Words)}
+		}
+		if err := json.NewEncoder(w).Encode(rep); err != nil {
+			return fmt.Errorf("encode json report: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (use text|json)", opts.Format)
+	}
+}
+
+func nonNil(entries []Entry) []Entry {
+	if entries == nil {
+		return []Entry{}
+	}
+	return entries
+}
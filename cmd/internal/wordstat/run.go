@@ -2,9 +2,12 @@ package wordstat
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"iter"
+	"regexp"
 )
 
 func ValidateOptions(opts Options) error {
@@ -20,48 +23,249 @@ func ValidateOptions(opts Options) error {
 	default:
 		return fmt.Errorf("invalid -format=%q (use text|json)", opts.Format)
 	}
-	if opts.Workers < 1 {
-		return fmt.Errorf("invalid -workers=%d (must be >= 1", opts.Workers)
+	if opts.Workers < 0 {
+		return fmt.Errorf("invalid -workers=%d (must be >= 0)", opts.Workers)
+	}
+	if _, err := LookupTokenizer(opts.Tokenizer); err != nil {
+		return fmt.Errorf("invalid -tokenizer: %w", err)
+	}
+	if err := opts.Normalizer.Validate(); err != nil {
+		return err
+	}
+	switch opts.Unit {
+	case "", "word", "char-ngram":
+	case "char":
+		if opts.Ngram > 1 {
+			return fmt.Errorf("invalid -ngram=%d with -unit=char (use -unit=char-ngram)", opts.Ngram)
+		}
+	default:
+		return fmt.Errorf("invalid -unit=%q (use word|char|char-ngram)", opts.Unit)
+	}
+	if opts.Pattern != "" {
+		if _, err := compilePattern(opts.Pattern, opts.Group); err != nil {
+			return err
+		}
+		if opts.Unit == "char" || opts.Unit == "char-ngram" {
+			return fmt.Errorf("invalid -pattern with -unit=%s (use -unit=word)", opts.Unit)
+		}
+		if opts.Tokenizer != "" {
+			return fmt.Errorf("invalid -pattern with -tokenizer=%s (matches are the tokens)", opts.Tokenizer)
+		}
+	}
+	switch opts.Input {
+	case "", "text", "html", "xml", "markdown":
+	default:
+		return fmt.Errorf("invalid -input=%q (use text|html|xml|markdown)", opts.Input)
+	}
+	if !opts.Markdown.IsZero() && opts.Input != "markdown" {
+		return fmt.Errorf("invalid -markdown-parts without -input=markdown")
+	}
+	if err := validateEngine(opts.Engine); err != nil {
+		return err
+	}
+	if opts.MaxMem < 0 {
+		return fmt.Errorf("invalid -max-mem=%d (must be >= 0)", opts.MaxMem)
+	}
+	if opts.MaxMem > 0 && (opts.Approx || opts.Sketch) {
+		return fmt.Errorf("invalid -max-mem with -approx|-sketch (they already run in bounded memory)")
+	}
+	if opts.ByDocument && (opts.Approx || opts.Sketch || opts.MaxMem > 0) {
+		return fmt.Errorf("invalid -by-doc with -approx|-sketch|-max-mem")
+	}
+	if opts.Sketch {
+		switch {
+		case opts.Stem != "":
+			return fmt.Errorf("invalid -stem with -sketch")
+		case opts.Approx:
+			return fmt.Errorf("invalid -approx with -sketch")
+		case opts.classify():
+			return fmt.Errorf("invalid -classes with -sketch (a sketch cannot list its words)")
+		}
+	}
+	if opts.Approx {
+		if opts.Stem != "" {
+			return fmt.Errorf("invalid -stem with -approx (stems cannot be merged in a summary)")
+		}
+		if opts.ApproxCap < 0 {
+			return fmt.Errorf("invalid -approx-cap=%d (must be >= 0)", opts.ApproxCap)
+		}
+	}
+	if opts.classify() && (opts.Unit == "char" || opts.Unit == "char-ngram") {
+		return fmt.Errorf("invalid -classes with -unit=%s (token classes need -unit=word)", opts.Unit)
+	}
+	if _, err := stemmerFor(opts.Stem); err != nil {
+		return err
+	}
+	if opts.Ngram < 0 {
+		return fmt.Errorf("invalid -ngram=%d (must be >= 1)", opts.Ngram)
 	}
 	return nil
 }
 
 func RunCtx(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
-	if opts.Workers <= 0 {
-		opts.Workers = 1
-	}
-	if err := ValidateOptions(opts); err != nil {
+	return RunDocsCtx(ctx, SingleDocument("", r), w, opts)
+}
+
+// RunDocsCtx печатает общий отчёт по всем документам.
+func RunDocsCtx(ctx context.Context, docs iter.Seq2[Document, error], w io.Writer, opts Options) error {
+	tok, re, err := prepareRun(&opts)
+	if err != nil {
 		return err
 	}
+	docs = inputDocuments(docs, opts)
 
-	var counts map[string]int
-	var err error
-
-	if opts.Buffered {
-		counts, err = CountReaderBuffered(ctx, r)
-	} else {
-		in := bufio.NewReader(r)
+	if opts.Sketch {
+		sk, err := buildSketch(ctx, docs, tok, re, opts)
+		if err != nil {
+			return err
+		}
+		return printSketchReport(w, sk, tok, re, opts)
+	}
 
-		if opts.Workers <= 1 {
-			counts, err = CountBufio(ctx, in)
-		} else {
-			counts, err = CountBufioConcurrent(ctx, in, opts.Workers, 1024)
+	if opts.ByDocument {
+		total, reports, err := documentReports(ctx, docs, tok, re, opts)
+		if err != nil {
+			return err
 		}
+		return PrintDocumentsReport(w, total, reports, opts)
 	}
 
+	var entries []Entry
+	switch {
+	case opts.Approx:
+		entries, err = approxEntries(ctx, docs, tok, re, opts)
+	case opts.MaxMem > 0:
+		entries, err = spillEntries(ctx, docs, tok, re, opts)
+	default:
+		entries, err = exactEntries(ctx, docs, tok, re, opts)
+	}
 	if err != nil {
 		return err
 	}
 
-	entries := BuildEntries(counts)
+	return PrintReport(w, finishEntries(entries, opts), opts)
+}
+
+func finishEntries(entries []Entry, opts Options) []Entry {
 	entries = FilterMin(entries, opts.Min)
+	if opts.Ngram > 1 {
+		expandNgrams(entries)
+	}
+	if opts.classify() {
+		entries = classifyEntries(entries, opts.Classes)
+	}
 	SortEntries(entries, opts)
 
-	if opts.K > 0 && opts.K < len(entries) {
+	if opts.ByClass && opts.K > 0 {
+		entries = limitPerClass(entries, opts.K)
+	} else if opts.K > 0 && opts.K < len(entries) {
 		entries = entries[:opts.K]
 	}
+	return entries
+}
+
+func documentReports(ctx context.Context, docs iter.Seq2[Document, error], tok Tokenizer, re *regexp.Regexp, opts Options) ([]Entry, []DocumentReport, error) {
+	all := counterFor(opts)
+	var reports []DocumentReport
+	for doc, err := range docs {
+		if err != nil {
+			return nil, nil, err
+		}
+		counts := counterFor(opts)
+		if err := countDocument(ctx, doc, tok, re, opts, counts); err != nil {
+			return nil, nil, err
+		}
+		reports = append(reports, DocumentReport{Document: doc.Name, Words: finishEntries(counterEntries(counts, opts), opts)})
+		if err := all.Merge(counts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return finishEntries(counterEntries(all, opts), opts), reports, nil
+}
+
+// prepareRun меняет opts (стоп-слова, Ngram для символов), вызывать один раз
+func prepareRun(opts *Options) (Tokenizer, *regexp.Regexp, error) {
+	if opts.Workers < 0 {
+		opts.Workers = 0
+	}
+	if err := ValidateOptions(*opts); err != nil {
+		return nil, nil, err
+	}
+
+	tok, err := tokenizerFor(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !opts.Normalizer.isDefault() {
+		tok = WithNormalizer(tok, opts.Normalizer)
+		opts.Stopwords = opts.Stopwords.normalized(opts.Normalizer)
+	}
+
+	var re *regexp.Regexp
+	if opts.Pattern != "" {
+		re, _ = compilePattern(opts.Pattern, opts.Group)
+	}
+	return tok, re, nil
+}
 
-	return PrintReport(w, entries, opts)
+func exactEntries(ctx context.Context, docs iter.Seq2[Document, error], tok Tokenizer, re *regexp.Regexp, opts Options) ([]Entry, error) {
+	counts := counterFor(opts)
+	for doc, err := range docs {
+		if err != nil {
+			return nil, err
+		}
+		if err := countDocument(ctx, doc, tok, re, opts, counts); err != nil {
+			return nil, err
+		}
+	}
+	return counterEntries(counts, opts), nil
+}
+
+// символьные n-граммы строит сам токенизатор, поэтому opts.Ngram сбрасывается
+func tokenizerFor(opts *Options) (Tokenizer, error) {
+	switch opts.Unit {
+	case "char":
+		return NewCharTokenizer(opts.LettersOnly, 1), nil
+	case "char-ngram":
+		n := opts.Ngram
+		if n < 2 {
+			n = 2
+		}
+		opts.Ngram = 1
+		return NewCharTokenizer(opts.LettersOnly, n), nil
+	default:
+		if opts.Tokenizer == "" && opts.classify() {
+			return TypedTokenizer, nil
+		}
+		return LookupTokenizer(opts.Tokenizer)
+	}
+}
+
+func (opts Options) classify() bool {
+	return opts.ByClass || !opts.Classes.IsZero()
+}
+
+func countDocument(ctx context.Context, doc Document, tok Tokenizer, re *regexp.Regexp, opts Options, dst Counter) error {
+	c := chooseEngine(doc, re, opts)
+	reportEngine(c, opts)
+
+	r := doc.R
+	if doc.Data != nil {
+		if re == nil {
+			return countBytesParallel(ctx, doc.Data, c.Workers, tok, opts.Ngram, dst)
+		}
+		r = bytes.NewReader(doc.Data)
+	}
+	switch c.Engine {
+	case "pattern":
+		return countPattern(ctx, bufio.NewReader(r), re, opts.Group, tok, opts.Ngram, dst)
+	case "buffered":
+		return countReaderBuffered(ctx, r, c.Workers, tok, opts.Ngram, dst)
+	case "concurrent":
+		return countBufioConcurrent(ctx, bufio.NewReader(r), c.Workers, concurrentBlockSize, tok, opts.Ngram, dst)
+	default:
+		return countBufio(ctx, bufio.NewReader(r), tok, opts.Ngram, dst)
+	}
 }
 
 func Run(r io.Reader, w io.Writer, opts Options) error {
@@ -0,0 +1,93 @@
+package wordstat
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ScanUnicodeWords - упрощённый UAX #29: слово из букв, цифр, меток и '_';
+// ' и - внутри слова ("don't", "covid-19"), '.' и ',' между цифрами ("3.14", "1,024").
+func ScanUnicodeWords(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) {
+		if !atEOF && !utf8.FullRune(data[start:]) {
+			return start, nil, nil
+		}
+		r, w := utf8.DecodeRune(data[start:])
+		if isWordRune(r) {
+			break
+		}
+		start += w
+	}
+	if start >= len(data) {
+		return start, nil, nil
+	}
+
+	i, end := start, start
+	var prev rune
+	for i < len(data) {
+		if !atEOF && !utf8.FullRune(data[i:]) {
+			return start, nil, nil
+		}
+		r, w := utf8.DecodeRune(data[i:])
+		if isWordRune(r) {
+			prev = r
+			i += w
+			end = i
+			continue
+		}
+		if !isMidRune(r) {
+			break
+		}
+		// соединитель - часть слова, только если за ним подходящая руна
+		j := i + w
+		if j >= len(data) || (!atEOF && !utf8.FullRune(data[j:])) {
+			if !atEOF {
+				return start, nil, nil
+			}
+			break
+		}
+		next, _ := utf8.DecodeRune(data[j:])
+		if !joins(prev, r, next) {
+			break
+		}
+		i = j
+	}
+	if i >= len(data) && !atEOF {
+		return start, nil, nil
+	}
+	return end, data[start:end], nil
+}
+
+func isWordRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_'
+	}
+	if r == utf8.RuneError {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
+
+func isMidRune(r rune) bool {
+	switch r {
+	case '\'', '’', '-', '‐', '‑', '.', ',':
+		return true
+	default:
+		return false
+	}
+}
+
+func joins(prev, mid, next rune) bool {
+	if !isWordRune(next) {
+		return false
+	}
+	switch mid {
+	case ',':
+		return unicode.IsDigit(prev) && unicode.IsDigit(next)
+	case '.':
+		return unicode.IsDigit(prev) == unicode.IsDigit(next)
+	default:
+		return true
+	}
+}
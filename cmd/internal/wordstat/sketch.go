@@ -0,0 +1,304 @@
+package wordstat
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+// HyperLogLog 16 KB (~0.8%) и Count-Min 4 x 65536 (2 MB): завышение
+// не больше e/65536 от total с вероятностью 98%
+const (
+	DefaultSketchPrecision = 14
+	DefaultSketchWidth     = 1 << 16
+	DefaultSketchDepth     = 4
+)
+
+var sketchMagic = [4]byte{'W', 'S', 'K', '1'}
+
+// Sketch - сводка в фиксированной памяти: HyperLogLog (distinct) и Count-Min (Count).
+// Sketch-и одного размера сливаются.
+type Sketch struct {
+	precision uint8
+	depth     int
+	width     int
+	total     uint64
+	registers []uint8
+	counters  []uint64 // depth строк по width
+}
+
+// NewSketch: нулевые параметры - значения по умолчанию.
+func NewSketch(precision, width, depth int) (*Sketch, error) {
+	if precision == 0 {
+		precision = DefaultSketchPrecision
+	}
+	if width == 0 {
+		width = DefaultSketchWidth
+	}
+	if depth == 0 {
+		depth = DefaultSketchDepth
+	}
+	if precision < 4 || precision > 18 {
+		return nil, fmt.Errorf("invalid sketch precision %d (use 4..18)", precision)
+	}
+	if width < 1 || width > math.MaxUint32 || depth < 1 || depth > 32 {
+		return nil, fmt.Errorf("invalid sketch size %dx%d", depth, width)
+	}
+	return &Sketch{
+		precision: uint8(precision),
+		depth:     depth,
+		width:     width,
+		registers: make([]uint8, 1<<precision),
+		counters:  make([]uint64, depth*width),
+	}, nil
+}
+
+// FNV-1a + финализатор murmur3; стабилен между процессами
+func hash64(b []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (s *Sketch) Add(word string) {
+	s.addN([]byte(word), 1)
+}
+
+func (s *Sketch) AddN(word string, n int) {
+	s.addN([]byte(word), n)
+}
+
+func (s *Sketch) add(b []byte) { s.addN(b, 1) }
+
+func (s *Sketch) addN(b []byte, n int) {
+	if len(b) == 0 || n <= 0 {
+		return
+	}
+	s.total += uint64(n)
+	h := hash64(b)
+
+	p := s.precision
+	idx := h >> (64 - p)
+	rank := uint8(bits.LeadingZeros64(h<<p|1<<(p-1))) + 1
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+
+	for i := range s.depth {
+		s.counters[i*s.width+s.column(h, i)] += uint64(n)
+	}
+}
+
+// двойное хеширование (Kirsch-Mitzenmacher)
+func (s *Sketch) column(h uint64, i int) int {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	return int((h1 + uint32(i)*h2) % uint32(s.width))
+}
+
+func (s *Sketch) Total() uint64 { return s.total }
+
+func (s *Sketch) Distinct() uint64 {
+	m := float64(len(s.registers))
+	var sum float64
+	zeros := 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	var alpha float64
+	switch len(s.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// на малых значениях точнее linear counting
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(est))
+}
+
+// Count никогда не занижает.
+func (s *Sketch) Count(word string) uint64 {
+	h := hash64([]byte(word))
+	est := uint64(math.MaxUint64)
+	for i := range s.depth {
+		est = min(est, s.counters[i*s.width+s.column(h, i)])
+	}
+	return est
+}
+
+// ErrorBound - e/width от total (с вероятностью 1-e^-depth)
+func (s *Sketch) ErrorBound() uint64 {
+	return uint64(math.Ceil(math.E / float64(s.width) * float64(s.total)))
+}
+
+func (s *Sketch) Merge(o *Sketch) error {
+	if s.precision != o.precision || s.width != o.width || s.depth != o.depth {
+		return fmt.Errorf("merge sketch: parameters differ (%d/%dx%d vs %d/%dx%d)",
+			s.precision, s.depth, s.width, o.precision, o.depth, o.width)
+	}
+	s.total += o.total
+	for i, r := range o.registers {
+		s.registers[i] = max(s.registers[i], r)
+	}
+	for i, c := range o.counters {
+		s.counters[i] += c
+	}
+	return nil
+}
+
+// "WSK1", precision, depth, width (uint32), total (uint64), registers, counters; little-endian
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 18+len(s.registers)+8*len(s.counters))
+	buf = append(buf, sketchMagic[:]...)
+	buf = append(buf, s.precision, uint8(s.depth))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(s.width))
+	buf = binary.LittleEndian.AppendUint64(buf, s.total)
+	buf = append(buf, s.registers...)
+	for _, c := range s.counters {
+		buf = binary.LittleEndian.AppendUint64(buf, c)
+	}
+	return buf, nil
+}
+
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 18 || !bytes.Equal(data[:4], sketchMagic[:]) {
+		return errors.New("unmarshal sketch: not a wordstat sketch")
+	}
+	precision, depth, width := int(data[4]), int(data[5]), int(binary.LittleEndian.Uint32(data[6:10]))
+	// выделяем таблицы, только если данных на них хватает
+	if precision == 0 || precision > 18 || depth == 0 || width == 0 || len(data)-18 != 1<<precision+8*depth*width {
+		return errors.New("unmarshal sketch: truncated or corrupt data")
+	}
+	sk, err := NewSketch(precision, width, depth)
+	if err != nil {
+		return fmt.Errorf("unmarshal sketch: %w", err)
+	}
+	sk.total = binary.LittleEndian.Uint64(data[10:18])
+	data = data[18:]
+	copy(sk.registers, data)
+	data = data[len(sk.registers):]
+	for i := range sk.counters {
+		sk.counters[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+	*s = *sk
+	return nil
+}
+
+func BuildSketch(ctx context.Context, docs iter.Seq2[Document, error], opts Options) (*Sketch, error) {
+	opts.Sketch = true
+	tok, re, err := prepareRun(&opts)
+	if err != nil {
+		return nil, err
+	}
+	return buildSketch(ctx, inputDocuments(docs, opts), tok, re, opts)
+}
+
+func buildSketch(ctx context.Context, docs iter.Seq2[Document, error], tok Tokenizer, re *regexp.Regexp, opts Options) (*Sketch, error) {
+	sk, _ := NewSketch(0, 0, 0)
+	var sink keyAdder = sk
+	if len(opts.Stopwords) > 0 {
+		sink = stopFilter{next: sk, sw: opts.Stopwords, n: opts.Ngram}
+	}
+	for doc, err := range docs {
+		if err != nil {
+			return nil, err
+		}
+		reportEngine(streamChoice(doc, "stream", "-sketch"), opts)
+		r := doc.R
+		if doc.Data != nil {
+			r = bytes.NewReader(doc.Data)
+		}
+		if err := streamDocument(ctx, r, tok, re, opts, sink); err != nil {
+			return nil, err
+		}
+	}
+	return sk, nil
+}
+
+type keyList []string
+
+func (l *keyList) add(key []byte) {
+	if len(key) > 0 {
+		*l = append(*l, string(key))
+	}
+}
+
+type SketchReport struct {
+	Total    uint64  `json:"total"`
+	Distinct uint64  `json:"distinct"`
+	Words    []Entry `json:"words"`
+}
+
+// запросы разбираются и нормализуются так же, как считаемый текст
+func PrintSketchReport(w io.Writer, s *Sketch, opts Options) error {
+	opts.Sketch = true
+	tok, re, err := prepareRun(&opts)
+	if err != nil {
+		return err
+	}
+	return printSketchReport(w, s, tok, re, opts)
+}
+
+func printSketchReport(w io.Writer, s *Sketch, tok Tokenizer, re *regexp.Regexp, opts Options) error {
+	rep := SketchReport{Total: s.Total(), Distinct: s.Distinct(), Words: []Entry{}}
+	bound := int(s.ErrorBound())
+	for _, q := range opts.Queries {
+		var keys keyList
+		if err := streamDocument(context.Background(), strings.NewReader(q), tok, re, opts, &keys); err != nil {
+			return fmt.Errorf("sketch query %q: %w", q, err)
+		}
+		if len(keys) == 0 {
+			rep.Words = append(rep.Words, Entry{Word: q})
+		}
+		// запрос может дать несколько ключей - отвечаем на каждый
+		for _, key := range keys {
+			count := int(min(s.Count(key), math.MaxInt))
+			if opts.Ngram > 1 {
+				key, _ = splitNgram(key)
+			}
+			rep.Words = append(rep.Words, Entry{Word: key, Count: count, Error: min(bound, count)})
+		}
+	}
+
+	switch opts.Format {
+	case "", "text":
+		if _, err := fmt.Fprintf(w, "total %d\ndistinct %d\n", rep.Total, rep.Distinct); err != nil {
+			return fmt.Errorf("print sketch report: %w", err)
+		}
+		return PrintReport(w, rep.Words, opts)
+	case "json":
+		if err := json.NewEncoder(w).Encode(rep); err != nil {
+			return fmt.Errorf("encode json sketch report: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (use text|json)", opts.Format)
+	}
+}
@@ -0,0 +1,184 @@
+package wordstat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSketch_Distinct(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100_000} {
+		sk, _ := NewSketch(0, 0, 0)
+		for i := 0; i < n; i++ {
+			w := fmt.Sprint("w", i)
+			sk.Add(w)
+			sk.Add(w)
+		}
+		got := float64(sk.Distinct())
+		if math.Abs(got-float64(n)) > 0.03*float64(n) {
+			t.Fatalf("n=%d: Distinct()=%v", n, got)
+		}
+		if sk.Total() != uint64(2*n) {
+			t.Fatalf("n=%d: Total()=%d", n, sk.Total())
+		}
+	}
+}
+
+func TestSketch_Count(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	zipf := rand.NewZipf(rng, 1.1, 1, 50_000)
+
+	sk, _ := NewSketch(0, 1024, 4)
+	exact := map[string]uint64{}
+	for i := 0; i < 100_000; i++ {
+		w := fmt.Sprint("w", zipf.Uint64())
+		sk.Add(w)
+		exact[w]++
+	}
+
+	over := 0
+	for w, n := range exact {
+		got := sk.Count(w)
+		if got < n {
+			t.Fatalf("%s: Count()=%d below true count %d", w, got, n)
+		}
+		if got-n > sk.ErrorBound() {
+			over++
+		}
+	}
+	// the bound holds with probability 1-e^-4 per word
+	if over > len(exact)/20 {
+		t.Fatalf("%d of %d words exceed the error bound %d", over, len(exact), sk.ErrorBound())
+	}
+	if got := sk.Count("never-added"); got > sk.ErrorBound() {
+		t.Fatalf("Count(never-added)=%d", got)
+	}
+}
+
+func TestSketch_MergeAndMarshal(t *testing.T) {
+	a, _ := NewSketch(10, 64, 3)
+	b, _ := NewSketch(10, 64, 3)
+	all, _ := NewSketch(10, 64, 3)
+	for i := 0; i < 500; i++ {
+		w := fmt.Sprint("w", i%70)
+		if i%3 == 0 {
+			a.Add(w)
+		} else {
+			b.Add(w)
+		}
+		all.Add(w)
+	}
+
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var merged Sketch
+	if err := merged.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if err := merged.Merge(b); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	got, _ := merged.MarshalBinary()
+	want, _ := all.MarshalBinary()
+	if string(got) != string(want) {
+		t.Fatalf("merged sketch differs from one built over all words")
+	}
+
+	other, _ := NewSketch(10, 128, 3)
+	if err := merged.Merge(other); err == nil {
+		t.Fatalf("Merge() of different sizes: expected error")
+	}
+	for _, bad := range [][]byte{nil, []byte("WSK1"), []byte("nope and more bytes"), data[:len(data)-1]} {
+		var s Sketch
+		if err := s.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("UnmarshalBinary(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSketch_UnmarshalHugeHeader(t *testing.T) {
+	// 18 header bytes declaring 2^18 registers and a 32 x 2^32-1 table (1 TB)
+	data := append([]byte("WSK1"), 18, 32, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 'x', 'y')
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var s Sketch
+	if err := s.UnmarshalBinary(data); err == nil {
+		t.Fatalf("UnmarshalBinary(huge header): expected error")
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("UnmarshalBinary(huge header) allocated %d bytes", n)
+	}
+}
+
+func TestRun_Sketch(t *testing.T) {
+	input := "The cat, the dog. THE end"
+
+	var out strings.Builder
+	opts := Options{SortBy: "count", Tokenizer: "unicode", Sketch: true, Queries: []string{"the", "Cat", "bird"}}
+	if err := Run(strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "total 6\ndistinct 4\nthe 3 ±1\ncat 1 ±1\nbird 0\n"; out.String() != want {
+		t.Fatalf("got=%q want=%q", out.String(), want)
+	}
+
+	out.Reset()
+	opts = Options{SortBy: "count", Format: "json", Tokenizer: "unicode", Ngram: 2, Sketch: true, Queries: []string{"the cat"}, Stopwords: Stopwords{"end": {}}}
+	if err := RunDocsCtx(context.Background(), SingleDocument("", strings.NewReader(input)), &out, opts); err != nil {
+		t.Fatalf("RunDocsCtx() error = %v", err)
+	}
+	var rep SketchReport
+	if err := json.Unmarshal([]byte(out.String()), &rep); err != nil {
+		t.Fatalf("bad json: %v out=%q", err, out.String())
+	}
+	if rep.Total != 4 || rep.Distinct != 4 || len(rep.Words) != 1 || rep.Words[0].Word != "the cat" || rep.Words[0].Count != 1 {
+		t.Fatalf("got=%+v", rep)
+	}
+
+	for _, opts := range []Options{
+		{SortBy: "word", Workers: 1, Sketch: true, Stem: "en"},
+		{SortBy: "word", Workers: 1, Sketch: true, Approx: true},
+		{SortBy: "word", Workers: 1, Sketch: true, ByClass: true},
+	} {
+		if err := ValidateOptions(opts); err == nil {
+			t.Fatalf("ValidateOptions(%+v): expected error", opts)
+		}
+	}
+}
+
+func TestPrintSketchReport_Tokenizer(t *testing.T) {
+	RegisterTokenizer("test-csv-sketch", csvTokenizer{})
+	ctx := context.Background()
+
+	tests := []struct {
+		opts  Options
+		input string
+		want  string
+	}{
+		{Options{Tokenizer: "test-csv-sketch", Queries: []string{"New York", "new york"}}, "New York,Paris,New York", "total 3\ndistinct 2\nNew York 2 ±1\nnew york 0\n"},
+		{Options{Tokenizer: "unicode", Queries: []string{"Hello,"}}, "hello, world! HELLO", "total 3\ndistinct 2\nhello 2 ±1\n"},
+		{Options{Unit: "char", Queries: []string{"Ab"}}, "abba", "total 4\ndistinct 2\na 2 ±1\nb 2 ±1\n"},
+	}
+	for _, tt := range tests {
+		tt.opts.SortBy = "count"
+		sk, err := BuildSketch(ctx, SingleDocument("", strings.NewReader(tt.input)), tt.opts)
+		if err != nil {
+			t.Fatalf("BuildSketch(%+v) error = %v", tt.opts, err)
+		}
+		var out strings.Builder
+		if err := PrintSketchReport(&out, sk, tt.opts); err != nil {
+			t.Fatalf("PrintSketchReport(%+v) error = %v", tt.opts, err)
+		}
+		if out.String() != tt.want {
+			t.Fatalf("%+v: got=%q want=%q", tt.opts, out.String(), tt.want)
+		}
+	}
+}
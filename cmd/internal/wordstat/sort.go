@@ -6,6 +6,9 @@ func SortEntries(entries []Entry, opts Options) {
 	switch opts.SortBy {
 	case "count":
 		sort.Slice(entries, func(i, j int) bool {
+			if opts.ByClass && entries[i].Class != entries[j].Class {
+				return classRank(entries[i].Class) < classRank(entries[j].Class)
+			}
 			if entries[i].Count != entries[j].Count {
 				return entries[i].Count > entries[j].Count
 			}
@@ -13,6 +16,9 @@ func SortEntries(entries []Entry, opts Options) {
 		})
 	case "word":
 		sort.Slice(entries, func(i, j int) bool {
+			if opts.ByClass && entries[i].Class != entries[j].Class {
+				return classRank(entries[i].Class) < classRank(entries[j].Class)
+			}
 			return entries[i].Word < entries[j].Word
 		})
 	}
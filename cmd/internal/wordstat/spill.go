@@ -0,0 +1,291 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// примерная цена записи в map помимо самого ключа
+const spillEntryOverhead = 64
+
+// spillCounter считает в map не больше maxMem байт; переполненная map уходит
+// во временный файл отсортированным run-ом. group - ключ -> основа (или nil).
+type spillCounter struct {
+	maxMem int64
+	group  func(string) string
+	fail   context.CancelCauseFunc
+
+	counts map[string]int
+	size   int64
+	runs   []*os.File
+	err    error
+}
+
+func newSpillCounter(maxMem int64, group func(string) string, fail context.CancelCauseFunc) *spillCounter {
+	return &spillCounter{maxMem: maxMem, group: group, fail: fail, counts: make(map[string]int)}
+}
+
+func (c *spillCounter) add(b []byte) {
+	if len(b) == 0 || c.err != nil {
+		return
+	}
+	if _, ok := c.counts[string(b)]; ok {
+		c.counts[string(b)]++
+		return
+	}
+	c.counts[string(b)] = 1
+	c.size += int64(len(b)) + spillEntryOverhead
+	if c.size > c.maxMem {
+		if err := c.spill(); err != nil {
+			c.err = err
+			c.fail(err)
+		}
+	}
+}
+
+func (c *spillCounter) sorted() []spillRecord {
+	run := make([]spillRecord, 0, len(c.counts))
+	for k, n := range c.counts {
+		run = append(run, spillRecord{key: k, group: c.groupOf(k), count: n})
+	}
+	slices.SortFunc(run, compareSpillRecords)
+	return run
+}
+
+func (c *spillCounter) groupOf(key string) string {
+	if c.group == nil {
+		return key
+	}
+	return c.group(key)
+}
+
+func (c *spillCounter) spill() error {
+	f, err := os.CreateTemp("", "wordstat-spill-*")
+	if err != nil {
+		return fmt.Errorf("spill: %w", err)
+	}
+	c.runs = append(c.runs, f)
+
+	w := bufio.NewWriter(f)
+	var buf []byte
+	for _, r := range c.sorted() {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(r.key)))
+		buf = append(buf, r.key...)
+		buf = binary.AppendUvarint(buf, uint64(r.count))
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("spill: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("spill: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("spill: %w", err)
+	}
+
+	clear(c.counts)
+	c.size = 0
+	return nil
+}
+
+func (c *spillCounter) close() {
+	for _, f := range c.runs {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	c.runs = nil
+}
+
+// merge: k-way слияние run-ов и map, ключи одной группы - одна запись (как BuildStemmedEntries)
+func (c *spillCounter) merge(ctx context.Context, emit func(Entry)) error {
+	cursors := make(spillHeap, 0, len(c.runs)+1)
+	for _, f := range c.runs {
+		cur := &spillCursor{r: bufio.NewReader(f), name: f.Name(), group: c.groupOf}
+		if err := cur.next(); err != nil {
+			return err
+		}
+		if cur.ok {
+			cursors = append(cursors, cur)
+		}
+	}
+	if mem := c.sorted(); len(mem) > 0 {
+		clear(c.counts)
+		cursors = append(cursors, &spillCursor{mem: mem[1:], rec: mem[0], ok: true})
+	}
+	heap.Init(&cursors)
+
+	var group Entry
+	formCount := 0
+	key, keyCount := "", 0
+	flushKey := func() {
+		if keyCount == 0 {
+			return
+		}
+		g := c.groupOf(key)
+		if group.Count > 0 && group.Word == g {
+			group.Count += keyCount
+			if keyCount > formCount {
+				group.Form, formCount = key, keyCount
+			}
+			return
+		}
+		if group.Count > 0 {
+			emit(group)
+		}
+		group = Entry{Word: g, Count: keyCount}
+		if c.group != nil {
+			group.Form = key
+		}
+		formCount = keyCount
+	}
+
+	for i := 0; len(cursors) > 0; i++ {
+		if i%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		cur := cursors[0]
+		r := cur.rec
+		if r.key != key {
+			flushKey()
+			key, keyCount = r.key, 0
+		}
+		keyCount += r.count
+		if err := cur.next(); err != nil {
+			return err
+		}
+		if cur.ok {
+			heap.Fix(&cursors, 0)
+		} else {
+			heap.Pop(&cursors)
+		}
+	}
+	flushKey()
+	if group.Count > 0 {
+		emit(group)
+	}
+	return nil
+}
+
+type spillRecord struct {
+	key, group string
+	count      int
+}
+
+func compareSpillRecords(a, b spillRecord) int {
+	return cmp.Or(cmp.Compare(a.group, b.group), cmp.Compare(a.key, b.key))
+}
+
+type spillCursor struct {
+	r     *bufio.Reader
+	name  string
+	group func(string) string
+	mem   []spillRecord
+
+	rec spillRecord
+	ok  bool
+}
+
+func (c *spillCursor) next() error {
+	if c.r == nil {
+		c.ok = len(c.mem) > 0
+		if c.ok {
+			c.rec, c.mem = c.mem[0], c.mem[1:]
+		}
+		return nil
+	}
+
+	n, err := binary.ReadUvarint(c.r)
+	if err == io.EOF {
+		c.ok = false
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read spill %s: %w", c.name, err)
+	}
+	key := make([]byte, n)
+	if _, err := io.ReadFull(c.r, key); err != nil {
+		return fmt.Errorf("read spill %s: %w", c.name, err)
+	}
+	count, err := binary.ReadUvarint(c.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return fmt.Errorf("read spill %s: %w", c.name, err)
+	}
+	c.rec = spillRecord{key: string(key), group: c.group(string(key)), count: int(count)}
+	c.ok = true
+	return nil
+}
+
+type spillHeap []*spillCursor
+
+func (h spillHeap) Len() int           { return len(h) }
+func (h spillHeap) Less(i, j int) bool { return compareSpillRecords(h[i].rec, h[j].rec) < 0 }
+func (h spillHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *spillHeap) Push(x any)        { *h = append(*h, x.(*spillCursor)) }
+func (h *spillHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// spillEntries считает точно в пределах opts.MaxMem; Workers не используется
+func spillEntries(ctx context.Context, docs iter.Seq2[Document, error], tok Tokenizer, re *regexp.Regexp, opts Options) ([]Entry, error) {
+	var group func(string) string
+	if stem, _ := stemmerFor(opts.Stem); stem != nil {
+		group = stemNgram(stem, opts.Ngram)
+	}
+
+	ctx, fail := context.WithCancelCause(ctx)
+	defer fail(nil)
+
+	c := newSpillCounter(opts.MaxMem, group, fail)
+	defer c.close()
+
+	var sink keyAdder = c
+	if len(opts.Stopwords) > 0 {
+		sink = stopFilter{next: c, sw: opts.Stopwords, n: opts.Ngram}
+	}
+	for doc, err := range docs {
+		if err != nil {
+			return nil, err
+		}
+		reportEngine(streamChoice(doc, "spill", "-max-mem"), opts)
+		r := doc.R
+		if doc.Data != nil {
+			r = bytes.NewReader(doc.Data)
+		}
+		if err := streamDocument(ctx, r, tok, re, opts, sink); err != nil {
+			if c.err != nil {
+				return nil, c.err
+			}
+			return nil, err
+		}
+	}
+	if topKDirect(opts) {
+		h := &topHeap{k: opts.K, less: entryLess(opts)}
+		err := c.merge(ctx, func(e Entry) {
+			if e.Count >= opts.Min {
+				h.offer(e)
+			}
+		})
+		return h.items, err
+	}
+	var entries []Entry
+	err := c.merge(ctx, func(e Entry) { entries = append(entries, e) })
+	return entries, err
+}
@@ -0,0 +1,78 @@
+package wordstat
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_MaxMemMatchesInMemory(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	rng := rand.New(rand.NewSource(7))
+	input := randomText(rng, 3000) + " running runs run ran Run"
+
+	for _, opts := range []Options{
+		{SortBy: "count"},
+		{SortBy: "word", Tokenizer: "unicode", Ngram: 2},
+		{SortBy: "count", Tokenizer: "unicode", Stem: "en", Min: 2},
+		{SortBy: "count", Stopwords: Stopwords{"a": {}, "ccc": {}}, Ngram: 3},
+		{SortBy: "count", Pattern: `\pL+`},
+		{SortBy: "count", Unit: "char-ngram", Ngram: 2},
+	} {
+		var want strings.Builder
+		if err := Run(strings.NewReader(input), &want, opts); err != nil {
+			t.Fatalf("Run(%+v) error = %v", opts, err)
+		}
+		for _, maxMem := range []int64{1000, 1 << 30} {
+			opts.MaxMem = maxMem
+			var got strings.Builder
+			if err := Run(strings.NewReader(input), &got, opts); err != nil {
+				t.Fatalf("Run(%+v) error = %v", opts, err)
+			}
+			if got.String() != want.String() {
+				t.Fatalf("Run(%+v):\ngot:\n%s\nwant:\n%s", opts, got.String(), want.String())
+			}
+		}
+	}
+
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Fatalf("temp files left behind: %v", left)
+	}
+}
+
+func TestRun_MaxMemErrors(t *testing.T) {
+	// the spill directory does not exist
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	err := Run(strings.NewReader("a b c d e f"), &strings.Builder{}, Options{SortBy: "word", MaxMem: 100})
+	if err == nil || !strings.Contains(err.Error(), "spill") {
+		t.Fatalf("err=%v, want a spill error", err)
+	}
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RunCtx(ctx, strings.NewReader("a b c d e f"), &strings.Builder{}, Options{SortBy: "word", MaxMem: 100})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v want context.Canceled", err)
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Fatalf("temp files left behind: %v", left)
+	}
+
+	for _, opts := range []Options{
+		{SortBy: "word", Workers: 1, MaxMem: -1},
+		{SortBy: "word", Workers: 1, MaxMem: 1, Approx: true},
+		{SortBy: "word", Workers: 1, MaxMem: 1, Sketch: true},
+	} {
+		if err := ValidateOptions(opts); err == nil {
+			t.Fatalf("ValidateOptions(%+v): expected error", opts)
+		}
+	}
+}
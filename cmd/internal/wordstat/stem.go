@@ -0,0 +1,52 @@
+package wordstat
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+)
+
+func stemmerFor(name string) (func(string) string, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "en":
+		return StemEnglish, nil
+	case "ru":
+		return StemRussian, nil
+	default:
+		return nil, fmt.Errorf("invalid -stem=%q (use en|ru)", name)
+	}
+}
+
+// BuildStemmedEntries группирует слова по основе; Form - самое частое слово группы.
+func BuildStemmedEntries(counts map[string]int, stem func(string) string) []Entry {
+	return buildStemmedEntries(maps.All(counts), stem)
+}
+
+func buildStemmedEntries(words iter.Seq2[string, int], stem func(string) string) []Entry {
+	type group struct {
+		count     int
+		form      string
+		formCount int
+	}
+	groups := make(map[string]*group)
+	for w, c := range words {
+		s := stem(w)
+		g := groups[s]
+		if g == nil {
+			g = &group{}
+			groups[s] = g
+		}
+		g.count += c
+		if c > g.formCount || (c == g.formCount && w < g.form) {
+			g.form, g.formCount = w, c
+		}
+	}
+
+	entries := make([]Entry, 0, len(groups))
+	for s, g := range groups {
+		entries = append(entries, Entry{Word: s, Count: g.count, Form: g.form})
+	}
+	return entries
+}
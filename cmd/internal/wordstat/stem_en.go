@@ -0,0 +1,296 @@
+package wordstat
+
+import "strings"
+
+// StemEnglish - Snowball English (Porter2); слово в нижнем регистре, не-ASCII не меняется.
+func StemEnglish(word string) string {
+	for i := 0; i < len(word); i++ {
+		if word[i] >= 0x80 {
+			return word
+		}
+	}
+	if len(word) <= 2 {
+		return word
+	}
+	if s, ok := enExceptions1[word]; ok {
+		return s
+	}
+
+	w := []byte(strings.TrimPrefix(word, "'"))
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isEnVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+
+	st := enStemmer{w: w}
+	st.markRegions()
+
+	st.step0()
+	st.step1a()
+	if _, ok := enExceptions2[string(st.w)]; ok {
+		return restoreY(st.w)
+	}
+	st.step1b()
+	st.step1c()
+	st.step2()
+	st.step3()
+	st.step4()
+	st.step5()
+
+	return restoreY(st.w)
+}
+
+var enExceptions1 = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+	"singly": "singl",
+
+	// invariant forms
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos",
+	"bias": "bias", "andes": "andes",
+}
+
+var enExceptions2 = map[string]struct{}{
+	"inning": {}, "outing": {}, "canning": {}, "herring": {}, "earring": {},
+	"proceed": {}, "exceed": {}, "succeed": {},
+}
+
+type enStemmer struct {
+	w      []byte
+	r1, r2 int
+}
+
+func isEnVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	default:
+		return false
+	}
+}
+
+func restoreY(w []byte) string {
+	for i := range w {
+		if w[i] == 'Y' {
+			w[i] = 'y'
+		}
+	}
+	return string(w)
+}
+
+func regionAfter(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isEnVowel(w[i]) && isEnVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func (s *enStemmer) markRegions() {
+	s.r1 = len(s.w)
+	for _, p := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(s.w), p) {
+			s.r1 = len(p)
+			break
+		}
+	}
+	if s.r1 == len(s.w) {
+		s.r1 = regionAfter(s.w, 0)
+	}
+	s.r2 = regionAfter(s.w, s.r1)
+}
+
+func (s *enStemmer) hasSuffix(suf string) bool {
+	return strings.HasSuffix(string(s.w), suf)
+}
+
+func (s *enStemmer) longest(suffixes ...string) string {
+	best := ""
+	for _, suf := range suffixes {
+		if len(suf) > len(best) && s.hasSuffix(suf) {
+			best = suf
+		}
+	}
+	return best
+}
+
+func (s *enStemmer) inR1(suf string) bool { return len(s.w)-len(suf) >= s.r1 }
+func (s *enStemmer) inR2(suf string) bool { return len(s.w)-len(suf) >= s.r2 }
+
+func (s *enStemmer) replace(suf, with string) {
+	s.w = append(s.w[:len(s.w)-len(suf)], with...)
+}
+
+func (s *enStemmer) endsShortSyllable(end int) bool {
+	w := s.w[:end]
+	n := len(w)
+	if n == 2 {
+		return isEnVowel(w[0]) && !isEnVowel(w[1])
+	}
+	if n < 3 {
+		return false
+	}
+	c := w[n-1]
+	return !isEnVowel(w[n-3]) && isEnVowel(w[n-2]) && !isEnVowel(c) && c != 'w' && c != 'x' && c != 'Y'
+}
+
+func (s *enStemmer) isShort() bool {
+	return s.r1 >= len(s.w) && s.endsShortSyllable(len(s.w))
+}
+
+func (s *enStemmer) containsVowel(end int) bool {
+	for i := 0; i < end; i++ {
+		if isEnVowel(s.w[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *enStemmer) step0() {
+	if suf := s.longest("'s'", "'s", "'"); suf != "" {
+		s.replace(suf, "")
+	}
+}
+
+func (s *enStemmer) step1a() {
+	switch suf := s.longest("sses", "ied", "ies", "us", "ss", "s"); suf {
+	case "sses":
+		s.replace(suf, "ss")
+	case "ied", "ies":
+		if len(s.w)-len(suf) > 1 {
+			s.replace(suf, "i")
+		} else {
+			s.replace(suf, "ie")
+		}
+	case "s":
+		if s.containsVowel(len(s.w) - 2) {
+			s.replace(suf, "")
+		}
+	}
+}
+
+func (s *enStemmer) step1b() {
+	switch suf := s.longest("eed", "eedly", "ed", "edly", "ing", "ingly"); suf {
+	case "":
+	case "eed", "eedly":
+		if s.inR1(suf) {
+			s.replace(suf, "ee")
+		}
+	default:
+		if !s.containsVowel(len(s.w) - len(suf)) {
+			return
+		}
+		s.replace(suf, "")
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.w = append(s.w, 'e')
+		case s.endsDouble():
+			s.w = s.w[:len(s.w)-1]
+		case s.isShort():
+			s.w = append(s.w, 'e')
+		}
+	}
+}
+
+func (s *enStemmer) endsDouble() bool {
+	for _, d := range []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"} {
+		if s.hasSuffix(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *enStemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isEnVowel(s.w[n-2]) {
+		s.w[n-1] = 'i'
+	}
+}
+
+var enStep2 = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+	"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+	"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous",
+	"ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble",
+	"ogi": "og", "fulli": "ful", "lessli": "less", "li": "",
+}
+
+var enStep2Suffixes = mapKeys(enStep2)
+
+func (s *enStemmer) step2() {
+	suf := s.longest(enStep2Suffixes...)
+	if suf == "" || !s.inR1(suf) {
+		return
+	}
+	n := len(s.w) - len(suf)
+	switch suf {
+	case "ogi":
+		if n == 0 || s.w[n-1] != 'l' {
+			return
+		}
+	case "li":
+		if n == 0 || !strings.ContainsRune("cdeghkmnrt", rune(s.w[n-1])) {
+			return
+		}
+	}
+	s.replace(suf, enStep2[suf])
+}
+
+var enStep3 = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic",
+	"ical": "ic", "ful": "", "ness": "", "ative": "",
+}
+
+var enStep3Suffixes = mapKeys(enStep3)
+
+func (s *enStemmer) step3() {
+	suf := s.longest(enStep3Suffixes...)
+	if suf == "" || !s.inR1(suf) {
+		return
+	}
+	if suf == "ative" && !s.inR2(suf) {
+		return
+	}
+	s.replace(suf, enStep3[suf])
+}
+
+func (s *enStemmer) step4() {
+	suf := s.longest("al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement",
+		"ment", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion")
+	if suf == "" || !s.inR2(suf) {
+		return
+	}
+	if suf == "ion" {
+		n := len(s.w) - len(suf)
+		if n == 0 || (s.w[n-1] != 's' && s.w[n-1] != 't') {
+			return
+		}
+	}
+	s.replace(suf, "")
+}
+
+func (s *enStemmer) step5() {
+	n := len(s.w)
+	switch {
+	case s.hasSuffix("e"):
+		if s.inR2("e") || (s.inR1("e") && !s.endsShortSyllable(n-1)) {
+			s.w = s.w[:n-1]
+		}
+	case s.hasSuffix("ll"):
+		if s.inR2("l") {
+			s.w = s.w[:n-1]
+		}
+	}
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
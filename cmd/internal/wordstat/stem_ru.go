@@ -0,0 +1,153 @@
+package wordstat
+
+import "strings"
+
+// StemRussian - Snowball Russian; слово в нижнем регистре, ё = е.
+func StemRussian(word string) string {
+	w := []rune(strings.ReplaceAll(word, "ё", "е"))
+
+	rv := len(w)
+	for i, r := range w {
+		if isRuVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := ruRegionAfter(w, 0)
+	r2 := ruRegionAfter(w, r1)
+
+	st := ruStemmer{w: w, rv: rv, r2: r2}
+
+	// Step 1
+	if !st.removeGrouped(ruPerfectiveGerund1, ruPerfectiveGerund2) {
+		st.remove(ruReflexive)
+		if st.remove(ruAdjective) {
+			st.removeGrouped(ruParticiple1, ruParticiple2)
+		} else if !st.removeGrouped(ruVerb1, ruVerb2) {
+			st.remove(ruNoun)
+		}
+	}
+
+	// Step 2
+	st.remove([]string{"и"})
+
+	// Step 3
+	if suf := st.longest(ruDerivational); suf != "" && len(st.w)-len([]rune(suf)) >= st.r2 {
+		st.cut(suf)
+	}
+
+	// Step 4
+	switch {
+	case st.remove(ruSuperlative):
+		st.undoubleN()
+	case st.undoubleN():
+	default:
+		st.remove([]string{"ь"})
+	}
+
+	return string(st.w)
+}
+
+var (
+	ruPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	ruPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	ruReflexive         = []string{"ся", "сь"}
+	ruAdjective         = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым",
+		"ом", "его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruVerb1       = []string{
+		"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют",
+		"ны", "ть", "ешь", "нно",
+	}
+	ruVerb2 = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл",
+		"им", "ым", "ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены",
+		"ить", "ыть", "ишь", "ую", "ю",
+	}
+	ruNoun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией",
+		"ей", "ой", "ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах",
+		"иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+	ruSuperlative  = []string{"ейш", "ейше"}
+	ruDerivational = []string{"ост", "ость"}
+)
+
+func isRuVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+func ruRegionAfter(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRuVowel(w[i]) && isRuVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// все операции с суффиксами - в пределах RV
+type ruStemmer struct {
+	w      []rune
+	rv, r2 int
+}
+
+func (s *ruStemmer) endsWith(suf string) bool {
+	rs := []rune(suf)
+	if len(s.w)-len(rs) < s.rv {
+		return false
+	}
+	return string(s.w[len(s.w)-len(rs):]) == suf
+}
+
+func (s *ruStemmer) longest(suffixes []string) string {
+	best := ""
+	for _, suf := range suffixes {
+		if len(suf) > len(best) && s.endsWith(suf) {
+			best = suf
+		}
+	}
+	return best
+}
+
+func (s *ruStemmer) cut(suf string) {
+	s.w = s.w[:len(s.w)-len([]rune(suf))]
+}
+
+func (s *ruStemmer) remove(suffixes []string) bool {
+	suf := s.longest(suffixes)
+	if suf == "" {
+		return false
+	}
+	s.cut(suf)
+	return true
+}
+
+// суффиксы group1 - только после 'а' или 'я' внутри RV
+func (s *ruStemmer) removeGrouped(group1, group2 []string) bool {
+	s1, s2 := s.longest(group1), s.longest(group2)
+	if s2 != "" && len(s2) >= len(s1) {
+		s.cut(s2)
+		return true
+	}
+	if s1 == "" {
+		return false
+	}
+	i := len(s.w) - len([]rune(s1)) - 1
+	if i < s.rv || (s.w[i] != 'а' && s.w[i] != 'я') {
+		return false
+	}
+	s.cut(s1)
+	return true
+}
+
+func (s *ruStemmer) undoubleN() bool {
+	if !s.endsWith("нн") {
+		return false
+	}
+	s.w = s.w[:len(s.w)-1]
+	return true
+}
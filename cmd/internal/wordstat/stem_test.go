@@ -0,0 +1,72 @@
+package wordstat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStemEnglish(t *testing.T) {
+	tests := map[string]string{
+		"count": "count", "counts": "count", "counted": "count", "counting": "count",
+		"abandoned": "abandon", "abase": "abas", "abatement": "abat",
+		"consign": "consign", "consigned": "consign", "consignment": "consign",
+		"consistency": "consist", "consistently": "consist", "consolation": "consol",
+		"consolatory": "consolatori", "consolingly": "consol", "consolidated": "consolid",
+		"conspicuously": "conspicu", "conspiracy": "conspiraci", "constable": "constabl",
+		"generously": "generous", "communication": "communic",
+		"running": "run", "hopping": "hop", "caresses": "caress", "ponies": "poni",
+		"ties": "tie", "cried": "cri", "agreed": "agre", "feed": "feed", "happy": "happi",
+		"sky": "sky", "dying": "die", "knightly": "knight", "succeeding": "succeed",
+		"yelling": "yell", "it's": "it", "gas": "gas", "kiwis": "kiwi",
+		"organization": "organ", "civilization": "civil", "vietnamization": "vietnam",
+	}
+	for in, want := range tests {
+		if got := StemEnglish(in); got != want {
+			t.Errorf("StemEnglish(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStemRussian(t *testing.T) {
+	tests := map[string]string{
+		"слово": "слов", "слова": "слов", "словами": "слов",
+		"красивый": "красив", "красивая": "красив",
+		"читать": "чита", "читаю": "чита", "книги": "книг", "книга": "книг",
+		"ёлка": "елк",
+	}
+	for in, want := range tests {
+		if got := StemRussian(in); got != want {
+			t.Errorf("StemRussian(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRun_Stem(t *testing.T) {
+	// English stemming leaves Russian words alone
+	in := strings.NewReader("counts count counted counting counts слово слова словами")
+	var out strings.Builder
+
+	opts := Options{SortBy: "count", Min: 1, Stem: "en"}
+	if err := Run(in, &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "count 5 counts\nслова 1 слова\nсловами 1 словами\nслово 1 слово\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestBuildStemmedEntries(t *testing.T) {
+	counts := map[string]int{"слово": 2, "слова": 3, "словами": 1, "дом": 1}
+	entries := BuildStemmedEntries(counts, StemRussian)
+	SortEntries(entries, Options{SortBy: "count"})
+
+	want := []Entry{
+		{Word: "слов", Count: 6, Form: "слова"},
+		{Word: "дом", Count: 1, Form: "дом"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got=%v want=%v", entries, want)
+	}
+}
@@ -0,0 +1,93 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed stopwords/*.txt
+var builtinStopwordFiles embed.FS
+
+type Stopwords map[string]struct{}
+
+// LoadStopwords: слова через пробелы, строки с '#' - комментарии.
+func LoadStopwords(r io.Reader) (Stopwords, error) {
+	sw := make(Stopwords)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, w := range strings.Fields(line) {
+			if w = Normalize(w); w != "" {
+				sw[w] = struct{}{}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read stopwords: %w", err)
+	}
+	return sw, nil
+}
+
+func LoadStopwordsFile(path string) (Stopwords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sw, err := LoadStopwords(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sw, nil
+}
+
+func BuiltinStopwords(lang string) (Stopwords, bool) {
+	f, err := builtinStopwordFiles.Open("stopwords/" + lang + ".txt")
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	sw, err := LoadStopwords(f)
+	if err != nil {
+		return nil, false
+	}
+	return sw, true
+}
+
+func (sw Stopwords) Merge(other Stopwords) Stopwords {
+	if sw == nil {
+		sw = make(Stopwords, len(other))
+	}
+	for w := range other {
+		sw[w] = struct{}{}
+	}
+	return sw
+}
+
+// n-грамма - стоп, если начинается или кончается стоп-словом: "of the", но не "state of the art"
+func isStopKey(key []byte, sw Stopwords, n int) bool {
+	first, last := key, key
+	if n <= 1 {
+		_, stop := sw[string(key)]
+		return stop
+	}
+	if i := bytes.Index(key, []byte(ngramSep)); i >= 0 {
+		first = key[:i]
+		last = key[bytes.LastIndex(key, []byte(ngramSep))+len(ngramSep):]
+	}
+	if _, stop := sw[string(first)]; stop {
+		return true
+	}
+	_, stop := sw[string(last)]
+	return stop
+}
@@ -0,0 +1,53 @@
+package wordstat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadStopwords(t *testing.T) {
+	sw, err := LoadStopwords(strings.NewReader("# comment\nThe AND\n\n  Не\n"))
+	if err != nil {
+		t.Fatalf("LoadStopwords() error = %v", err)
+	}
+	for _, w := range []string{"the", "and", "не"} {
+		if _, ok := sw[w]; !ok {
+			t.Fatalf("missing %q in %v", w, sw)
+		}
+	}
+	if len(sw) != 3 {
+		t.Fatalf("len(sw) = %d, want 3: %v", len(sw), sw)
+	}
+}
+
+func TestBuiltinStopwords(t *testing.T) {
+	for lang, word := range map[string]string{"en": "the", "ru": "не"} {
+		sw, ok := BuiltinStopwords(lang)
+		if !ok {
+			t.Fatalf("BuiltinStopwords(%q) not found", lang)
+		}
+		if _, ok := sw[word]; !ok {
+			t.Fatalf("BuiltinStopwords(%q) lacks %q", lang, word)
+		}
+	}
+	if _, ok := BuiltinStopwords("xx"); ok {
+		t.Fatalf("expected no list for xx")
+	}
+}
+
+func TestRun_Stopwords(t *testing.T) {
+	en, _ := BuiltinStopwords("en")
+	ru, _ := BuiltinStopwords("ru")
+
+	in := strings.NewReader("The cat and the dog и кот не пёс cat")
+	var out strings.Builder
+
+	opts := Options{SortBy: "count", Min: 1, Stopwords: en.Merge(ru)}
+	if err := Run(in, &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "cat 2\ndog 1\nкот 1\nпёс 1\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
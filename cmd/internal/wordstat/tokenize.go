@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"strings"
 )
 
 func isSpace(b byte) bool {
@@ -17,29 +16,7 @@ func isSpace(b byte) bool {
 }
 
 func normalizeWordBytes(b []byte) string {
-	// Trim BOM bytes
-	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
-		b = b[3:]
-	}
-	ascii := true
-	for _, c := range b {
-		if c >= 0x80 {
-			ascii = false
-			break
-		}
-	}
-	if ascii {
-		for i, c := range b {
-			if 'A' <= c && c <= 'Z' {
-				b[i] = c + ('a' - 'A')
-			}
-		}
-		return string(b)
-	}
-
-	s := string(b)
-	s = strings.TrimPrefix(s, "\ufeff")
-	return strings.ToLower(s)
+	return string(AppendNormalizedWord(nil, b))
 }
 
 func ReadWord(r *bufio.Reader) (string, bool, error) {
@@ -78,3 +55,21 @@ func ReadWord(r *bufio.Reader) (string, bool, error) {
 
 	return normalizeWordBytes(buf), true, nil
 }
+
+// ScanWhitespaceWords делит по тем же ASCII-пробелам, что и ReadWord (Unicode-пробелы - не разделители).
+func ScanWhitespaceWords(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && isSpace(data[start]) {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if isSpace(data[i]) {
+			return i + 1, data[start:i], nil
+		}
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+	// просим ещё данных, ведущие пробелы уже отброшены
+	return start, nil, nil
+}
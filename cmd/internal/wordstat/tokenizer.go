@@ -0,0 +1,128 @@
+package wordstat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer: все движки гоняют один и тот же Tokenizer, поэтому счёт от движка не зависит.
+type Tokenizer interface {
+	// семантика bufio.SplitFunc; зовётся и на окнах потока, и на всём входе (atEOF=true)
+	Split(data []byte, atEOF bool) (advance int, token []byte, err error)
+
+	// не меняет и не сохраняет tok; пустое слово отбрасывается
+	AppendNormalized(dst, tok []byte) []byte
+}
+
+func NewTokenizer(split bufio.SplitFunc) Tokenizer {
+	return splitTokenizer{split: split}
+}
+
+type splitTokenizer struct {
+	split bufio.SplitFunc
+
+	spaceAligned bool
+}
+
+func (t splitTokenizer) Split(data []byte, atEOF bool) (int, []byte, error) {
+	return t.split(data, atEOF)
+}
+
+func (splitTokenizer) AppendNormalized(dst, tok []byte) []byte {
+	return AppendNormalizedWord(dst, tok)
+}
+
+var (
+	WhitespaceTokenizer Tokenizer = splitTokenizer{split: ScanWhitespaceWords, spaceAligned: true}
+	UnicodeTokenizer    Tokenizer = splitTokenizer{split: ScanUnicodeWords, spaceAligned: true}
+)
+
+// spaceAligned: токен никогда не пересекает ASCII-пробел, вход можно резать после любого пробела
+func spaceAligned(t Tokenizer) bool {
+	switch t := t.(type) {
+	case splitTokenizer:
+		return t.spaceAligned
+	case charTokenizer:
+		return true
+	case normalizedTokenizer:
+		return spaceAligned(t.Tokenizer)
+	default:
+		return false
+	}
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// AppendNormalizedWord срезает BOM и приводит tok к нижнему регистру.
+func AppendNormalizedWord(dst, tok []byte) []byte {
+	tok = bytes.TrimPrefix(tok, utf8BOM)
+	for len(tok) > 0 {
+		c := tok[0]
+		if c < utf8.RuneSelf {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			dst = append(dst, c)
+			tok = tok[1:]
+			continue
+		}
+		r, w := utf8.DecodeRune(tok)
+		dst = utf8.AppendRune(dst, unicode.ToLower(r))
+		tok = tok[w:]
+	}
+	return dst
+}
+
+var tokenizers = struct {
+	sync.RWMutex
+	m map[string]Tokenizer
+}{
+	m: map[string]Tokenizer{
+		"whitespace": WhitespaceTokenizer,
+		"unicode":    UnicodeTokenizer,
+	},
+}
+
+// RegisterTokenizer паникует, если t == nil или имя уже занято.
+func RegisterTokenizer(name string, t Tokenizer) {
+	if t == nil {
+		panic("wordstat: RegisterTokenizer tokenizer is nil")
+	}
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	if _, dup := tokenizers.m[name]; dup {
+		panic("wordstat: RegisterTokenizer called twice for " + name)
+	}
+	tokenizers.m[name] = t
+}
+
+// пустое имя - whitespace
+func LookupTokenizer(name string) (Tokenizer, error) {
+	if name == "" {
+		name = "whitespace"
+	}
+	tokenizers.RLock()
+	t, ok := tokenizers.m[name]
+	tokenizers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q (use %s)", name, strings.Join(TokenizerNames(), "|"))
+	}
+	return t, nil
+}
+
+func TokenizerNames() []string {
+	tokenizers.RLock()
+	defer tokenizers.RUnlock()
+	names := make([]string, 0, len(tokenizers.m))
+	for name := range tokenizers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+package wordstat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// режет по запятым и сохраняет регистр
+type csvTokenizer struct{}
+
+func (csvTokenizer) Split(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, ",\n"); i >= 0 {
+		return i + 1, bytes.TrimSpace(data[:i]), nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), bytes.TrimSpace(data), nil
+	}
+	return 0, nil, nil
+}
+
+func (csvTokenizer) AppendNormalized(dst, tok []byte) []byte {
+	return append(dst, tok...)
+}
+
+func TestRegisterTokenizer_AllEngines(t *testing.T) {
+	RegisterTokenizer("test-csv", csvTokenizer{})
+
+	input := "New York, Paris,new york\nNew York,,Paris"
+	want := "New York 2\nParis 2\nnew york 1\n"
+
+	for _, opts := range []Options{
+		{Workers: 1},
+		{Workers: 3},
+		{Buffered: true},
+	} {
+		opts.SortBy = "count"
+		opts.Tokenizer = "test-csv"
+
+		var out strings.Builder
+		if err := Run(strings.NewReader(input), &out, opts); err != nil {
+			t.Fatalf("Run(%+v) error = %v", opts, err)
+		}
+		if out.String() != want {
+			t.Fatalf("Run(%+v) got:\n%q\nwant:\n%q", opts, out.String(), want)
+		}
+	}
+}
+
+func TestRegisterTokenizer_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	RegisterTokenizer("whitespace", WhitespaceTokenizer)
+}
+
+func TestLookupTokenizer_Unknown(t *testing.T) {
+	if _, err := LookupTokenizer("nope"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := ValidateOptions(Options{SortBy: "word", Workers: 1, Tokenizer: "nope"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestAppendNormalizedWord(t *testing.T) {
+	tests := map[string]string{
+		"HeLLo":         "hello",
+		"\ufeffПРИВЕТ":  "привет",
+		"\ufeff":        "",
+		"ÀB\xffc":       "àb�c",
+		"already-lower": "already-lower",
+	}
+	for in, want := range tests {
+		if got := string(AppendNormalizedWord(nil, []byte(in))); got != want {
+			t.Fatalf("AppendNormalizedWord(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package wordstat
+
+import (
+	"container/heap"
+	"iter"
+	"maps"
+	"strings"
+)
+
+// TopK: куча на K элементов, O(n log K); результат не отсортирован, K = 0 - все.
+func TopK(counts map[string]int, opts Options) []Entry {
+	return topOf(entriesOf(maps.All(counts)), opts)
+}
+
+func selectTop(entries iter.Seq[Entry], opts Options) []Entry {
+	h := &topHeap{k: opts.K, less: entryLess(opts)}
+	for e := range entries {
+		if e.Count < opts.Min {
+			continue
+		}
+		h.offer(e)
+	}
+	return h.items
+}
+
+func entryLess(opts Options) func(a, b Entry) bool {
+	cmpWords := strings.Compare
+	if opts.Ngram > 1 {
+		cmpWords = compareWords
+	}
+	if opts.SortBy == "count" {
+		return func(a, b Entry) bool {
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return cmpWords(a.Word, b.Word) < 0
+		}
+	}
+	return func(a, b Entry) bool { return cmpWords(a.Word, b.Word) < 0 }
+}
+
+// compareWords сравнивает ключи n-грамм так, как их напечатает expandNgrams
+func compareWords(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		ca, cb := a[i], b[i]
+		if ca == ngramSep[0] {
+			ca = ' '
+		}
+		if cb == ngramSep[0] {
+			cb = ' '
+		}
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// худший - в корне
+type topHeap struct {
+	k     int
+	less  func(a, b Entry) bool
+	items []Entry
+}
+
+func (h *topHeap) offer(e Entry) {
+	if len(h.items) < h.k {
+		heap.Push(h, e)
+		return
+	}
+	if h.less(e, h.items[0]) {
+		h.items[0] = e
+		heap.Fix(h, 0)
+	}
+}
+
+func (h *topHeap) Len() int           { return len(h.items) }
+func (h *topHeap) Less(i, j int) bool { return h.less(h.items[j], h.items[i]) }
+func (h *topHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *topHeap) Push(x any)         { h.items = append(h.items, x.(Entry)) }
+func (h *topHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
+
+// фильтрам и лимитам по классам нужны все классифицированные записи
+func topKDirect(opts Options) bool {
+	return opts.K > 0 && !opts.classify()
+}
@@ -0,0 +1,67 @@
+package wordstat
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTopK_MatchesFullSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	counts := map[string]int{}
+	for i := 0; i < 500; i++ {
+		w := fmt.Sprintf("w%d", rng.Intn(300))
+		if rng.Intn(3) == 0 {
+			w += ngramSep + fmt.Sprint(5+rng.Intn(5))
+		}
+		if rng.Intn(10) == 0 {
+			// a -pattern token may hold a space: "w1 0" sorts before the
+			// printed n-gram "w1 5", though after its key "w1\x1f5"
+			w += " " + fmt.Sprint(rng.Intn(5))
+		}
+		counts[w] += 1 + rng.Intn(4)
+	}
+
+	for _, sortBy := range []string{"count", "word"} {
+		for _, k := range []int{1, 7, 100, 1000} {
+			for _, minCount := range []int{0, 3} {
+				opts := Options{SortBy: sortBy, K: k, Min: minCount}
+
+				want := FilterMin(BuildEntries(counts), minCount)
+				expandNgrams(want)
+				SortEntries(want, opts)
+				want = want[:min(k, len(want))]
+
+				got := TopK(counts, opts)
+				expandNgrams(got)
+				SortEntries(got, opts)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("%+v:\ngot=%v\nwant=%v", opts, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestTopK_WordOrderAsPrinted(t *testing.T) {
+	counts := map[string]int{"a" + ngramSep + "5": 1, "a 0": 1, "b": 1}
+	got := TopK(counts, Options{SortBy: "word", K: 1, Ngram: 2})
+	if len(got) != 1 || got[0].Word != "a 0" {
+		t.Fatalf("got=%+v, want the entry printed as \"a 0\"", got)
+	}
+}
+
+func TestRun_TopKStemmed(t *testing.T) {
+	input := "runs running run cats cat dog dogs dog bird"
+	opts := Options{SortBy: "count", Tokenizer: "unicode", Stem: "en", K: 2}
+
+	var out strings.Builder
+	if err := Run(strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "dog 3 dog\nrun 3 run\n"; out.String() != want {
+		t.Fatalf("got=%q want=%q", out.String(), want)
+	}
+}
@@ -3,12 +3,16 @@ package wordstat
 import (
 	"bufio"
 	"io"
-	"strings"
 )
 
 type Entry struct {
 	Word  string `json:"word"`
 	Count int    `json:"count"`
+	Form  string `json:"form,omitempty"`  // most frequent surface form when Word is a stem
+	Class string `json:"class,omitempty"` // token class with Options.Classes or ByClass
+	Error int    `json:"error,omitempty"` // with Options.Approx or Sketch: Count may exceed the true count by up to Error
+
+	Tokens []string `json:"tokens,omitempty"` // tokens of an n-gram; Word joins them with spaces
 }
 
 func FilterMin(entries []Entry, min int) []Entry {
@@ -35,8 +39,7 @@ func FilterMinInPlace(entries []Entry, min int) []Entry {
 }
 
 func Normalize(s string) string {
-	s = strings.TrimPrefix(s, "\ufeff")
-	return strings.ToLower(s)
+	return string(AppendNormalizedWord(nil, []byte(s)))
 }
 
 func ReadWords(r io.Reader) ([]string, error) {
@@ -68,7 +71,7 @@ func Count(words []string) map[string]int {
 func BuildEntries(counts map[string]int) []Entry {
 	entries := make([]Entry, 0, len(counts))
 	for w, c := range counts {
-		entries = append(entries, Entry{w, c})
+		entries = append(entries, Entry{Word: w, Count: c})
 	}
 	return entries
 }
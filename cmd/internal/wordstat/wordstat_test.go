@@ -1,6 +1,7 @@
 package wordstat
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -140,3 +141,52 @@ func TestRunCtx_Canceled(t *testing.T) {
 		t.Fatalf("expected context.Canceled")
 	}
 }
+
+func TestScanUnicodeWords(t *testing.T) {
+	in := "\ufeffHello, (hello) hello!\u00a0don't\u2003covid-19 1,024 3.14 -x- 'y' e.g. Привет—мир"
+	sc := bufio.NewScanner(strings.NewReader(in))
+	sc.Split(ScanUnicodeWords)
+
+	var got []string
+	for sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("scan error = %v", err)
+	}
+
+	want := []string{"Hello", "hello", "hello", "don't", "covid-19", "1,024", "3.14", "x", "y", "e.g", "Привет", "мир"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%q want=%q", got, want)
+	}
+}
+
+func TestRun_UnicodeTokenizer_AllEngines(t *testing.T) {
+	input := "Hello, (hello) HELLO!\u00a0мир\u2003Мир don't"
+	want := "hello 3\nмир 2\ndon't 1\n"
+
+	engines := []struct {
+		name string
+		opts Options
+	}{
+		{name: "bufio", opts: Options{Workers: 1}},
+		{name: "buffered", opts: Options{Buffered: true}},
+		{name: "concurrent", opts: Options{Workers: 4}},
+	}
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			opts := e.opts
+			opts.SortBy = "count"
+			opts.Min = 1
+			opts.Tokenizer = "unicode"
+
+			var out strings.Builder
+			if err := Run(strings.NewReader(input), &out, opts); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if out.String() != want {
+				t.Fatalf("got:\n%q\nwant:\n%q", out.String(), want)
+			}
+		})
+	}
+}
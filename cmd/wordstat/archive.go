@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"iter"
+	"path"
+	"strings"
+
+	"github.com/PetrovKirill00/go_week1/cmd/internal/wordstat"
+)
+
+// архивы открываются и без -include, а -include применяется к их членам
+var archiveExts = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".zip"}
+
+func isArchiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// члены называются archive:member, бинарные пропускаются
+func archiveMembers(archive string, members iter.Seq2[wordstat.Document, error], in inputOptions) iter.Seq2[wordstat.Document, error] {
+	return func(yield func(wordstat.Document, error) bool) {
+		for m, err := range members {
+			if err != nil {
+				yield(wordstat.Document{Name: archive}, fmt.Errorf("%s: %w", archive, err))
+				return
+			}
+			name := archive + ":" + memberPath(m.Name)
+			doc, binary, err := openMember(name, m, in.encoding)
+			if err != nil {
+				yield(wordstat.Document{Name: name}, err)
+				return
+			}
+			if binary {
+				if in.skipped != nil {
+					in.skipped(name, "binary")
+				}
+				continue
+			}
+			if !yield(doc, nil) {
+				return
+			}
+		}
+	}
+}
+
+func openMember(name string, m wordstat.Document, encoding string) (wordstat.Document, bool, error) {
+	r, format, err := wordstat.NewDecompressingReader(m.R)
+	if err != nil {
+		return wordstat.Document{}, false, fmt.Errorf("%s: %w", name, err)
+	}
+	if format != "" {
+		m.Size = 0
+	}
+	br := bufio.NewReaderSize(r, binarySniffLen)
+	if head, _ := br.Peek(binarySniffLen); wordstat.LooksBinary(head) {
+		return wordstat.Document{}, true, nil
+	}
+	dr, err := wordstat.NewDecodingReader(br, encoding)
+	if err != nil {
+		return wordstat.Document{}, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return wordstat.Document{Name: name, R: dr, Size: m.Size}, false, nil
+}
+
+func (wo walkOptions) keepMember(name string) bool {
+	name = memberPath(name)
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if excluded, _ := matchRules(wo.exclude, dir, true); excluded {
+			return false
+		}
+	}
+	if excluded, _ := matchRules(wo.exclude, name, false); excluded {
+		return false
+	}
+	if len(wo.include) > 0 {
+		included, _ := matchRules(wo.include, name, false)
+		return included
+	}
+	return true
+}
+
+// "./docs/a.md" -> docs/a.md
+func memberPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/PetrovKirill00/go_week1/cmd/internal/wordstat"
 )
 
 func buildWordstat(t *testing.T) string {
@@ -47,6 +54,23 @@ func TestCLI_Stdin_Text(t *testing.T) {
 	}
 }
 
+func TestCLI_Classes(t *testing.T) {
+	bin := buildWordstat(t)
+	cmd := exec.Command(bin, "-sort", "count", "-classes", "hashtag,mention")
+	cmd.Stdin = strings.NewReader("#Go is fun, #go @gopher https://go.dev")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	want := "#go 2 hashtag\n@gopher 1 mention\n"
+	if stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+}
+
 func TestCLI_BadSort_ExitCode(t *testing.T) {
 	bin := buildWordstat(t)
 	cmd := exec.Command(bin, "-sort", "wat")
@@ -64,3 +88,442 @@ func TestCLI_BadSort_ExitCode(t *testing.T) {
 		t.Fatalf("expectod error on stderr")
 	}
 }
+
+func TestCLI_StopwordsFile(t *testing.T) {
+	bin := buildWordstat(t)
+
+	list := filepath.Join(t.TempDir(), "stop.txt")
+	if err := os.WriteFile(list, []byte("# ours\nfoo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bin, "-sort", "count", "-stopwords", "en,"+list)
+	cmd.Stdin = strings.NewReader("the foo bar the bar")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	want := "bar 2\n"
+	if stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+}
+
+func TestCLI_NgramFiles(t *testing.T) {
+	bin := buildWordstat(t)
+
+	dir := t.TempDir()
+	f1, f2 := filepath.Join(dir, "1.txt"), filepath.Join(dir, "2.txt")
+	if err := os.WriteFile(f1, []byte("a b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f2, []byte("c a b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bin, "-sort", "count", "-ngram", "2", f1, f2)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	// "b c" would span the two files
+	want := "a b 2\nc a 1\n"
+	if stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+}
+
+func TestCLI_EncodingAuto(t *testing.T) {
+	bin := buildWordstat(t)
+
+	// "мир мир слово" in KOI8-R
+	koi8 := []byte{0xCD, 0xC9, 0xD2, ' ', 0xCD, 0xC9, 0xD2, ' ', 0xD3, 0xCC, 0xCF, 0xD7, 0xCF}
+	path := filepath.Join(t.TempDir(), "koi8.txt")
+	if err := os.WriteFile(path, koi8, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bin, "-encoding", "auto", "-sort", "count", path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	want := "мир 2\nслово 1\n"
+	if stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+}
+
+func TestCLI_Mmap(t *testing.T) {
+	bin := buildWordstat(t)
+
+	dir := t.TempDir()
+	utf8Path, koi8Path, emptyPath := filepath.Join(dir, "utf8.txt"), filepath.Join(dir, "koi8.txt"), filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(utf8Path, []byte("Мир a\nмир b a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// "мир" in KOI8-R
+	if err := os.WriteFile(koi8Path, []byte{0xCD, 0xC9, 0xD2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(emptyPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-mmap", utf8Path, koi8Path, emptyPath},
+		{"-mmap", "-workers", "4", utf8Path, koi8Path, emptyPath},
+		{utf8Path, koi8Path, emptyPath},
+	} {
+		cmd := exec.Command(bin, append([]string{"-encoding", "auto", "-sort", "count"}, args...)...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("%v: run error=%v stderr=%q", args, err, stderr.String())
+		}
+		want := "мир 3\na 2\nb 1\n"
+		if stdout.String() != want {
+			t.Fatalf("%v: got=%q want=%q", args, stdout.String(), want)
+		}
+	}
+
+	// stdin is a pipe here: -mmap falls back to reading it
+	cmd := exec.Command(bin, "-mmap")
+	cmd.Stdin = strings.NewReader("b a b")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("stdin: run error=%v stderr=%q", err, stderr.String())
+	}
+	if want := "a 1\nb 2\n"; stdout.String() != want {
+		t.Fatalf("stdin: got=%q want=%q", stdout.String(), want)
+	}
+}
+
+func TestCLI_SketchMerge(t *testing.T) {
+	bin := buildWordstat(t)
+
+	dir := t.TempDir()
+	a, b, aSketch, bSketch := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "a.wsk"), filepath.Join(dir, "b.wsk")
+	if err := os.WriteFile(a, []byte("Go go rust"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("go zig"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-sketch-out", aSketch, a},
+		{"-sketch-out", bSketch, b},
+		{"-sketch", "-query", "go,zig,java", "-sketch-in", aSketch + "," + bSketch},
+	} {
+		cmd := exec.Command(bin, args...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("%v: run error=%v stderr=%q", args, err, stderr.String())
+		}
+		if args[0] == "-sketch" {
+			want := "total 5\ndistinct 3\ngo 3 ±1\nzig 1 ±1\njava 0\n"
+			if stdout.String() != want {
+				t.Fatalf("got=%q want=%q", stdout.String(), want)
+			}
+		}
+	}
+}
+
+func TestCLI_MaxMem(t *testing.T) {
+	bin := buildWordstat(t)
+	tmp := t.TempDir()
+
+	cmd := exec.Command(bin, "-sort", "count", "-max-mem", "1K")
+	cmd.Env = append(os.Environ(), "TMPDIR="+tmp)
+	cmd.Stdin = strings.NewReader(strings.Repeat("b a a b c ", 50) + "x1 x2 x3 x4 x5 x6 x7 x8 x9 x10 x11 x12 x13 x14 x15 x16 x17 x18 x19 x20")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "a 100\nb 100\nc 50\nx1 1\n") {
+		t.Fatalf("got=%q", stdout.String())
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Fatalf("temp files left behind: %v", left)
+	}
+
+	cmd = exec.Command(bin, "-max-mem", "lots")
+	cmd.Stdin = strings.NewReader("a")
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected non-zero exit for -max-mem=lots")
+	}
+}
+
+func TestCLI_EngineVerbose(t *testing.T) {
+	bin := buildWordstat(t)
+	path := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(path, []byte("b a a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bin, "-v", "-engine", "auto", "-format", "json", path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	var choice wordstat.EngineChoice
+	if err := json.Unmarshal(stderr.Bytes(), &choice); err != nil {
+		t.Fatalf("stderr=%q: %v", stderr.String(), err)
+	}
+	if choice.Document != path || choice.Engine != "buffered" || choice.Size != 5 {
+		t.Fatalf("engine choice = %+v", choice)
+	}
+	if stdout.String() != `[{"word":"a","count":2},{"word":"b","count":1}]`+"\n" {
+		t.Fatalf("stdout=%q", stdout.String())
+	}
+
+	// по умолчанию - stream в один поток, auto включается явно
+	cmd = exec.Command(bin, "-v")
+	cmd.Stdin = strings.NewReader("b a a")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	if stderr.String() != "engine: stdin: stream, workers=1\n" {
+		t.Fatalf("stderr=%q", stderr.String())
+	}
+
+	// an explicit -workers=1 holds with -engine=concurrent, too
+	cmd = exec.Command(bin, "-v", "-engine", "concurrent", "-workers", "1")
+	cmd.Stdin = strings.NewReader("b a a")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	if stderr.String() != "engine: stdin: concurrent, workers=1\n" {
+		t.Fatalf("stderr=%q", stderr.String())
+	}
+
+	cmd = exec.Command(bin, "-engine", "turbo")
+	cmd.Stdin = strings.NewReader("a")
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected non-zero exit for -engine=turbo")
+	}
+}
+
+func TestCLI_Recursive(t *testing.T) {
+	bin := buildWordstat(t)
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		".gitignore":     "drafts/\n",
+		"guide.md":       "alpha beta",
+		"api/ref.md":     "beta gamma",
+		"api/notes.txt":  "delta",
+		"drafts/wip.md":  "draft",
+		"img/logo.png":   "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"api/gen/all.md": "generated",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(bin, "-r", "-include", "*.md,*.png", "-exclude", "api/gen/", "-ignore-files", ".gitignore", root)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	if want := "alpha 1\nbeta 2\ngamma 1\n"; stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+
+	cmd = exec.Command(bin, root)
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil || !strings.Contains(stderr.String(), "use -r") {
+		t.Fatalf("directory without -r: err=%v stderr=%q", err, stderr.String())
+	}
+}
+
+func TestCLI_Compressed(t *testing.T) {
+	bin := buildWordstat(t)
+	dir := t.TempDir()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("b a a"))
+	_ = zw.Close()
+	path := filepath.Join(dir, "app.log.1.gz")
+	if err := os.WriteFile(path, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte("b c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{path, "-"},
+		{"-mmap", path, "-"},
+		{"-r", dir},
+	} {
+		cmd := exec.Command(bin, args...)
+		cmd.Stdin = bytes.NewReader(gz.Bytes())
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("%q: run error=%v stderr=%q", args, err, stderr.String())
+		}
+		want := "a 4\nb 2\n"
+		if args[0] == "-r" {
+			want = "a 2\nb 2\nc 1\n"
+		}
+		if stdout.String() != want {
+			t.Fatalf("%q: got=%q want=%q", args, stdout.String(), want)
+		}
+	}
+}
+
+func TestCLI_Archives(t *testing.T) {
+	bin := buildWordstat(t)
+	dir := t.TempDir()
+
+	members := []struct{ name, data string }{
+		{"./docs/a.md", "alpha beta"},
+		{"./docs/b.txt", "beta"},
+		{"./vendor/v.md", "vendored"},
+		{"./img.bin", "\x00\x01\x02"},
+	}
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data))}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(m.data))
+		w, err := zw.Create(m.name[2:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(m.data))
+	}
+	_ = tw.Close()
+	_ = zw.Close()
+	var tgz bytes.Buffer
+	gw := gzip.NewWriter(&tgz)
+	_, _ = gw.Write(tarBuf.Bytes())
+	_ = gw.Close()
+	for name, data := range map[string][]byte{"c.tgz": tgz.Bytes(), "c.zip": zipBuf.Bytes()} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(stdin []byte, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(bin, args...)
+		cmd.Stdin = bytes.NewReader(stdin)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("%q: run error=%v stderr=%q", args, err, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	tgzPath, zipPath := filepath.Join(dir, "c.tgz"), filepath.Join(dir, "c.zip")
+	if got, want := run(nil, "-exclude", "vendor/", tgzPath, zipPath), "alpha 2\nbeta 4\n"; got != want {
+		t.Fatalf("tgz+zip: got=%q want=%q", got, want)
+	}
+	if got, want := run(nil, "-mmap", "-include", "*.md", zipPath), "alpha 1\nbeta 1\nvendored 1\n"; got != want {
+		t.Fatalf("zip -include: got=%q want=%q", got, want)
+	}
+	if got, want := run(tgz.Bytes(), "-include", "docs/*"), "alpha 1\nbeta 2\n"; got != want {
+		t.Fatalf("stdin tgz: got=%q want=%q", got, want)
+	}
+	want := "==> " + tgzPath + ":docs/a.md <==\nalpha 1\nbeta 1\n\n" +
+		"==> " + zipPath + ":docs/a.md <==\nalpha 1\nbeta 1\n\n" +
+		"==> total <==\nalpha 2\nbeta 2\n"
+	if got := run(nil, "-by-doc", "-r", "-include", "a.md", dir); got != want {
+		t.Fatalf("-r -by-doc: got=%q want=%q", got, want)
+	}
+}
+
+func TestCLI_InputHTML(t *testing.T) {
+	bin := buildWordstat(t)
+
+	cmd := exec.Command(bin, "-input", "html", "-tokenizer", "unicode", "-sort", "count")
+	cmd.Stdin = strings.NewReader(`<html><head><style>p { x: y }</style></head><body><div class="x">word&nbsp;word</div><p>Other</p></body></html>`)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run error=%v stderr=%q", err, stderr.String())
+	}
+	if want := "word 2\nother 1\n"; stdout.String() != want {
+		t.Fatalf("got=%q want=%q", stdout.String(), want)
+	}
+
+	cmd = exec.Command(bin, "-input", "pdf")
+	cmd.Stdin = strings.NewReader("a")
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected non-zero exit for -input=pdf")
+	}
+}
+
+func TestCLI_InputMarkdown(t *testing.T) {
+	bin := buildWordstat(t)
+	doc := "# Usage\n\nRun the [tool](https://example.com/tool):\n\n```sh\ntool run run\n```\n"
+
+	for parts, want := range map[string]string{
+		"":         "run 1\nthe 1\ntool 1\nusage 1\n",
+		"code":     "run 2\ntool 1\n",
+		"headings": "usage 1\n",
+	} {
+		cmd := exec.Command(bin, "-input", "markdown", "-markdown-parts", parts, "-tokenizer", "unicode")
+		cmd.Stdin = strings.NewReader(doc)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("parts=%q: run error=%v stderr=%q", parts, err, stderr.String())
+		}
+		if stdout.String() != want {
+			t.Fatalf("parts=%q: got=%q want=%q", parts, stdout.String(), want)
+		}
+	}
+
+	cmd := exec.Command(bin, "-markdown-parts", "code")
+	cmd.Stdin = strings.NewReader("a")
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected non-zero exit for -markdown-parts without -input=markdown")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// шаблон со слэшем (не последним) - по пути от каталога, иначе по имени; ** - любые каталоги
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!pattern": re-include what an earlier rule ignored
+	dirOnly  bool // "pattern/": matches directories only
+	anchored bool
+}
+
+func parseIgnoreRule(line string) (r ignoreRule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return r, false
+	}
+	if line[0] == '!' {
+		r.negate, line = true, line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:] // "\#" and "\!" start literal patterns
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	r.anchored = strings.Contains(line, "/")
+	r.pattern = strings.TrimPrefix(line, "/")
+	return r, r.pattern != ""
+}
+
+func parseGlobs(list string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, p := range strings.Split(list, ",") {
+		r, ok := parseIgnoreRule(strings.TrimSpace(p))
+		if !ok {
+			continue
+		}
+		if _, err := path.Match(r.pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", p, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// нет файла - нет правил
+func loadIgnoreFile(name string) ([]ignoreRule, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreRule(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return rules, nil
+}
+
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		return matchGlob(r.pattern, path.Base(rel))
+	}
+	return matchGlob(r.pattern, rel)
+}
+
+// решает последнее совпавшее правило
+func matchRules(rules []ignoreRule, rel string, isDir bool) (ignored, matched bool) {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(rel, isDir) {
+			return !rules[i].negate, true
+		}
+	}
+	return false, false
+}
+
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := range len(name) + 1 {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
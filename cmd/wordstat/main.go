@@ -2,10 +2,17 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"iter"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/PetrovKirill00/go_week1/cmd/internal/wordstat"
@@ -19,7 +26,38 @@ func main() {
 	min := flag.Int("min", 1, "minimum count to include")
 	sortBy := flag.String("sort", "word", "sort by: word|count")
 	format := flag.String("format", "text", "output format: text|json")
-	workers := flag.Int("workers", 1, "number of counting workers (>=1)")
+	workers := flag.Int("workers", 0, "number of counting workers (0 = one per CPU with -engine=auto|concurrent, otherwise 1)")
+	engine := flag.String("engine", "", "counting engine: auto|stream|buffered|concurrent (empty = stream, concurrent with -workers>1; auto picks by input size, CPUs and memory limit)")
+	verbose := flag.Bool("v", false, "print the engine chosen for every input (JSON lines with -format=json) and the files -r skips to stderr")
+	input := flag.String("input", "text", "input format: text|html|xml|markdown (count only the text, without tags, comments, scripts and styles; markdown: without code, link URLs and markers)")
+	markdownParts := flag.String("markdown-parts", "", "with -input=markdown: comma-separated parts to count: prose|headings|code (empty = prose,headings)")
+	tokenizer := flag.String("tokenizer", "", "word splitting: "+strings.Join(wordstat.TokenizerNames(), "|")+" (default whitespace, typed with -classes|-by-class)")
+	stem := flag.String("stem", "", "group words by stem: en|ru (empty = off)")
+	stopwords := flag.String("stopwords", "", "comma-separated stopword lists to drop: en|ru|path to a file")
+	ngram := flag.Int("ngram", 1, "count phrases of N consecutive words, or N-rune windows with -unit=char-ngram (>=1)")
+	unit := flag.String("unit", "word", "what to count: word|char|char-ngram")
+	letters := flag.Bool("letters", false, "with -unit=char|char-ngram: count letters only")
+	normalize := flag.String("normalize", "", "Unicode normalization form: nfc|nfkc (empty = off)")
+	casefold := flag.String("casefold", "lower", "case handling: lower|fold|none")
+	yo := flag.Bool("yo", false, "merge Russian ё into е")
+	pattern := flag.String("pattern", "", "count matches of this regexp (per line) instead of words")
+	group := flag.Int("group", 0, "with -pattern: capture group to count (0 = whole match)")
+	approx := flag.Bool("approx", false, "approximate top-k in bounded memory (Space-Saving); counts are estimates with error bounds")
+	approxCap := flag.Int("approx-cap", 0, "with -approx: how many distinct words to track (0 = max(1024, 10*k))")
+	classes := flag.String("classes", "", "token classes to keep (word,hashtag) or drop (-url,-email): word|number|url|email|hashtag|mention|emoji")
+	byClass := flag.Bool("by-class", false, "group the report by token class; -k applies to every class")
+	maxMem := flag.String("max-mem", "", "exact counting within this much memory, spilling to temp files: bytes or 64K|512M|2G (empty = no limit)")
+	sketch := flag.Bool("sketch", false, "fixed-memory summary: total, distinct (HyperLogLog) and -query counts (Count-Min)")
+	query := flag.String("query", "", "with -sketch: comma-separated words (or phrases with -ngram) to estimate")
+	sketchOut := flag.String("sketch-out", "", "with -sketch: save the sketch to this file")
+	sketchIn := flag.String("sketch-in", "", "with -sketch: comma-separated saved sketches to merge in (input files are optional)")
+	recursive := flag.Bool("r", false, "count the files of directory arguments recursively (following symlinks, skipping binary files)")
+	include := flag.String("include", "", "with -r and in archives: comma-separated globs of the files to count, e.g. *.md,docs/**/*.txt (empty = all)")
+	exclude := flag.String("exclude", "", "with -r and in archives: comma-separated globs of files and directories to skip, e.g. vendor/,*.min.js")
+	byDoc := flag.Bool("by-doc", false, "report every input file (and archive member) separately, then the total")
+	ignoreFiles := flag.String("ignore-files", "", "with -r: comma-separated names of .gitignore-style files to honour in every directory, e.g. .gitignore")
+	useMmap := flag.Bool("mmap", false, "memory-map input files instead of reading them (falls back to reading for stdin pipes)")
+	encoding := flag.String("encoding", "utf-8", "input encoding: utf-8|utf-16le|utf-16be|cp1251|koi8-r|auto (utf-8 follows a UTF-16 BOM; auto also guesses CP1251/KOI8-R)")
 	flag.Parse()
 
 	opts := wordstat.Options{
@@ -28,43 +66,297 @@ func main() {
 		SortBy:  *sortBy,
 		Format:  *format,
 		Workers: *workers,
+		Engine:  *engine,
+
+		Input:     *input,
+		Tokenizer: *tokenizer,
+		Stem:      *stem,
+		Ngram:     *ngram,
+
+		Unit:        *unit,
+		LettersOnly: *letters,
+
+		Normalizer: wordstat.Normalizer{
+			Form:     *normalize,
+			CaseFold: *casefold,
+			MergeYo:  *yo,
+		},
+
+		Pattern: *pattern,
+		Group:   *group,
+
+		Approx:    *approx,
+		ApproxCap: *approxCap,
+
+		ByClass:    *byClass,
+		ByDocument: *byDoc,
+
+		Sketch: *sketch || *sketchOut != "" || *sketchIn != "",
+	}
+	if *verbose {
+		opts.OnEngine = engineLogger(os.Stderr, *format)
+	}
+	if *query != "" {
+		opts.Queries = strings.Split(*query, ",")
+	}
+
+	var err error
+	if opts.MaxMem, err = parseSize(*maxMem); err != nil {
+		fmt.Fprintln(os.Stderr, "error: invalid -max-mem:", err)
+		os.Exit(1)
+	}
+	if opts.Classes, err = wordstat.ParseClassFilter(*classes); err != nil {
+		fmt.Fprintln(os.Stderr, "error: invalid -classes:", err)
+		os.Exit(1)
+	}
+	if opts.Markdown, err = wordstat.ParseMarkdownParts(*markdownParts); err != nil {
+		fmt.Fprintln(os.Stderr, "error: invalid -markdown-parts:", err)
+		os.Exit(1)
+	}
+
+	if *stopwords != "" {
+		for _, name := range strings.Split(*stopwords, ",") {
+			sw, ok := wordstat.BuiltinStopwords(name)
+			if !ok {
+				sw, err = wordstat.LoadStopwordsFile(name)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "error:", err)
+					os.Exit(1)
+				}
+			}
+			opts.Stopwords = opts.Stopwords.Merge(sw)
+		}
+	}
+
+	if _, err := wordstat.CanonicalEncoding(*encoding); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
 
 	paths := flag.Args()
+	if len(paths) == 0 && *sketchIn == "" {
+		paths = []string{"-"}
+	}
+	wo := walkOptions{recursive: *recursive}
+	if wo.include, err = parseGlobs(*include); err != nil {
+		fmt.Fprintln(os.Stderr, "error: invalid -include:", err)
+		os.Exit(1)
+	}
+	if wo.exclude, err = parseGlobs(*exclude); err != nil {
+		fmt.Fprintln(os.Stderr, "error: invalid -exclude:", err)
+		os.Exit(1)
+	}
+	if *ignoreFiles != "" {
+		wo.ignoreFiles = strings.Split(*ignoreFiles, ",")
+	}
+	if *verbose {
+		wo.skipped = func(path, why string) { fmt.Fprintf(os.Stderr, "skip: %s: %s\n", path, why) }
+	}
+	in := inputOptions{encoding: *encoding, mmap: *useMmap, keep: wo.keepMember, skipped: wo.skipped}
+	docs := fileDocuments(inputPaths(paths, wo), in)
 
-	var in io.Reader = os.Stdin
+	if *sketchIn != "" || *sketchOut != "" {
+		err = runSketch(context.Background(), docs, out, opts, *sketchIn, *sketchOut)
+	} else {
+		err = wordstat.RunDocsCtx(context.Background(), docs, out, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
 
-	if len(paths) > 0 {
-		readers := make([]io.Reader, 0, len(paths)*2-1)
-		closers := make([]io.Closer, 0, len(paths))
+func engineLogger(w io.Writer, format string) func(wordstat.EngineChoice) {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		return func(c wordstat.EngineChoice) { _ = enc.Encode(c) }
+	}
+	return func(c wordstat.EngineChoice) {
+		name := c.Document
+		if name == "" || name == "-" {
+			name = "stdin"
+		}
+		fmt.Fprintf(w, "engine: %s: %s, workers=%d", name, c.Engine, c.Workers)
+		if c.Reason != "" {
+			fmt.Fprintf(w, " (%s)", c.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+}
 
-		closeAll := func() {
-			for i := len(closers) - 1; i >= 0; i-- {
-				_ = closers[i].Close()
+func runSketch(ctx context.Context, docs iter.Seq2[wordstat.Document, error], w io.Writer, opts wordstat.Options, in, out string) error {
+	sk, err := wordstat.BuildSketch(ctx, docs, opts)
+	if err != nil {
+		return err
+	}
+	if in != "" {
+		for _, p := range strings.Split(in, ",") {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			var o wordstat.Sketch
+			if err := o.UnmarshalBinary(data); err != nil {
+				return fmt.Errorf("%s: %w", p, err)
 			}
+			if err := sk.Merge(&o); err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+		}
+	}
+	if out != "" {
+		data, err := sk.MarshalBinary()
+		if err != nil {
+			return err
 		}
+		if err := os.WriteFile(out, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return wordstat.PrintSketchReport(w, sk, opts)
+}
+
+// K, M, G - двоичные
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	shift := 0
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("size %s out of range", s)
+	}
+	return n << shift, nil
+}
+
+var errNotMappable = errors.New("not a regular file")
 
-		for i, p := range paths {
-			f, err := os.Open(p)
+type inputOptions struct {
+	encoding string
+	mmap     bool
+	keep     func(member string) bool // archive members to count
+	skipped  func(path, why string)
+}
+
+// файлы открываются по одному и закрываются после подсчёта
+func fileDocuments(paths iter.Seq2[string, error], in inputOptions) iter.Seq2[wordstat.Document, error] {
+	return func(yield func(wordstat.Document, error) bool) {
+		for p, err := range paths {
 			if err != nil {
-				closeAll()
-				fmt.Fprintln(os.Stderr, "error:", err)
-				os.Exit(1)
+				yield(wordstat.Document{Name: p}, err)
+				return
 			}
-			closers = append(closers, f)
-			readers = append(readers, f)
+			docs, closeDocs, err := openDocuments(p, in)
+			if err != nil {
+				yield(wordstat.Document{Name: p}, err)
+				return
+			}
+			ok := true
+			for doc, err := range docs {
+				if !yield(doc, err) || err != nil {
+					ok = false
+					break
+				}
+			}
+			closeDocs()
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// с -mmap обычный файл без сжатия в UTF-8 считается прямо из page cache
+func openDocuments(path string, in inputOptions) (iter.Seq2[wordstat.Document, error], func(), error) {
+	f := os.Stdin
+	cleanup := func() {}
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() { _ = f.Close() }
+	}
 
-			if i+1 < len(paths) {
-				readers = append(readers, strings.NewReader("\n"))
+	var r io.Reader = f
+	var ra io.ReaderAt // random access for zip archives
+	size := fileSize(f)
+	if size > 0 {
+		ra = f
+	}
+	if in.mmap {
+		if data, unmap, err := mmapFile(f); err == nil {
+			closeFile := cleanup
+			cleanup = func() {
+				_ = unmap()
+				closeFile()
+			}
+			enc, _ := wordstat.SniffEncoding(data[:min(len(data), 4096)], in.encoding)
+			if enc == "utf-8" && wordstat.DetectCompression(data) == "" && wordstat.DetectArchive(data) == "" {
+				return oneDocument(wordstat.Document{Name: path, Data: data}), cleanup, nil
 			}
+			r, ra = bytes.NewReader(data), bytes.NewReader(data)
 		}
-		defer closeAll()
+	}
+	fail := func(err error) (iter.Seq2[wordstat.Document, error], func(), error) {
+		cleanup()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
 
-		in = io.MultiReader(readers...)
+	r, format, err := wordstat.NewDecompressingReader(r)
+	if err != nil {
+		return fail(err)
+	}
+	if format != "" {
+		size, ra = 0, nil // the decompressed size is not known
 	}
+	br := bufio.NewReaderSize(r, 4096)
+	head, _ := br.Peek(512) // read errors resurface when counting
 
-	if err := wordstat.Run(in, out, opts); err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(1)
+	switch wordstat.DetectArchive(head) {
+	case "tar":
+		return archiveMembers(path, wordstat.TarDocuments(br, in.keep), in), cleanup, nil
+	case "zip":
+		if ra == nil {
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return fail(err)
+			}
+			ra, size = bytes.NewReader(data), int64(len(data))
+		}
+		return archiveMembers(path, wordstat.ZipDocuments(ra, size, in.keep), in), cleanup, nil
+	}
+
+	dr, err := wordstat.NewDecodingReader(br, in.encoding)
+	if err != nil {
+		return fail(err)
+	}
+	return oneDocument(wordstat.Document{Name: path, R: dr, Size: size}), cleanup, nil
+}
+
+func oneDocument(doc wordstat.Document) iter.Seq2[wordstat.Document, error] {
+	return func(yield func(wordstat.Document, error) bool) { yield(doc, nil) }
+}
+
+// 0 - не обычный файл
+func fileSize(f *os.File) int64 {
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0
 	}
+	return fi.Size()
 }
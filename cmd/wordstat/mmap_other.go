@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(*os.File) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/PetrovKirill00/go_week1/cmd/internal/wordstat"
+)
+
+const binarySniffLen = 8000
+
+type walkOptions struct {
+	recursive   bool
+	include     []ignoreRule // files to count (all if empty); paths are relative to the walked argument
+	exclude     []ignoreRule // files and directories to skip
+	ignoreFiles []string     // ignore files read in every directory, e.g. .gitignore
+	skipped     func(path, why string)
+}
+
+// каталоги с -r: по имени, по симлинкам (каждый путь - один раз), без бинарных и исключённых
+func inputPaths(paths []string, wo walkOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		w := &walker{walkOptions: wo, seen: make(map[string]bool), yield: yield}
+		for _, p := range paths {
+			if p != "-" {
+				if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+					if !wo.recursive {
+						yield(p, fmt.Errorf("%s: is a directory (use -r)", p))
+						return
+					}
+					if !w.dir(p, p, nil) {
+						return
+					}
+					continue
+				}
+			}
+			if !yield(p, nil) {
+				return
+			}
+		}
+	}
+}
+
+type walker struct {
+	walkOptions
+	seen  map[string]bool // real paths of the directories and files visited
+	yield func(string, error) bool
+}
+
+type ignoreList struct {
+	dir   string
+	rules []ignoreRule
+}
+
+func (w *walker) visit(p string) (bool, error) {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return false, err
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return false, err
+	}
+	if w.seen[real] {
+		return false, nil
+	}
+	w.seen[real] = true
+	return true, nil
+}
+
+func (w *walker) skip(p, why string) {
+	if w.skipped != nil {
+		w.skipped(p, why)
+	}
+}
+
+// false - остановиться
+func (w *walker) dir(root, d string, ignores []ignoreList) bool {
+	ok, err := w.visit(d)
+	if err != nil {
+		w.yield(d, err)
+		return false
+	}
+	if !ok {
+		w.skip(d, "already visited (symlink)")
+		return true
+	}
+
+	entries, err := os.ReadDir(d)
+	if err != nil {
+		w.yield(d, err)
+		return false
+	}
+	for _, name := range w.ignoreFiles {
+		rules, err := loadIgnoreFile(filepath.Join(d, name))
+		if err != nil {
+			w.yield(d, err)
+			return false
+		}
+		if rules != nil {
+			ignores = append(slices.Clip(ignores), ignoreList{dir: d, rules: rules})
+		}
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(d, e.Name())
+		fi, err := os.Stat(p) // follows symlinks
+		if err != nil {
+			w.skip(p, err.Error())
+			continue
+		}
+		isDir := fi.IsDir()
+		if !isDir && !fi.Mode().IsRegular() {
+			w.skip(p, "not a regular file")
+			continue
+		}
+		if why := w.excluded(root, p, isDir, ignores); why != "" {
+			w.skip(p, why)
+			continue
+		}
+
+		if isDir {
+			if !w.dir(root, p, ignores) {
+				return false
+			}
+			continue
+		}
+		if ok, err := w.visit(p); err != nil {
+			w.skip(p, err.Error())
+			continue
+		} else if !ok {
+			w.skip(p, "already visited")
+			continue
+		}
+		if binary, err := isBinaryFile(p); err != nil {
+			w.skip(p, err.Error())
+			continue
+		} else if binary {
+			w.skip(p, "binary")
+			continue
+		}
+		if !w.yield(p, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *walker) excluded(root, p string, isDir bool, ignores []ignoreList) string {
+	if !isDir && slices.Contains(w.ignoreFiles, filepath.Base(p)) {
+		return "ignore file"
+	}
+	if isDir && filepath.Base(p) == ".git" && slices.Contains(w.ignoreFiles, ".gitignore") {
+		return "git directory"
+	}
+	// решает самый глубокий ignore-файл, как в git
+	for i := len(ignores) - 1; i >= 0; i-- {
+		if ignored, matched := matchRules(ignores[i].rules, relSlash(ignores[i].dir, p), isDir); matched {
+			if ignored {
+				return "ignored"
+			}
+			break
+		}
+	}
+	rel := relSlash(root, p)
+	if ignored, _ := matchRules(w.exclude, rel, isDir); ignored {
+		return "excluded"
+	}
+	if !isDir && len(w.include) > 0 && !isArchiveName(p) {
+		if included, _ := matchRules(w.include, rel, false); !included {
+			return "not included"
+		}
+	}
+	return ""
+}
+
+func relSlash(base, p string) string {
+	rel, err := filepath.Rel(base, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// сжатый текст и архивы считаются
+func isBinaryFile(p string) (bool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r, _, err := wordstat.NewDecompressingReader(f)
+	if err != nil {
+		return false, err
+	}
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return wordstat.LooksBinary(head[:n]) && wordstat.DetectArchive(head[:n]) == "", nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseGlobs("*.log, /build/, docs/**/*.md, !keep.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.log", false, true},
+		{"x/y/a.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"x/build", true, false},
+		{"docs/a.md", false, true},
+		{"docs/x/y/a.md", false, true},
+		{"other/docs/a.md", false, false},
+		{"a.md", false, false},
+	}
+	for _, tt := range tests {
+		if got, _ := matchRules(rules, tt.rel, tt.isDir); got != tt.ignored {
+			t.Errorf("matchRules(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.ignored)
+		}
+	}
+
+	if _, err := parseGlobs("[a"); err == nil {
+		t.Errorf("parseGlobs([a) error = nil")
+	}
+}
+
+func TestInputPaths(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":        "*.log\nvendor/\n",
+		".git/config":       "[core]\n",
+		"a.md":              "a",
+		"a.log":             "log",
+		"bin.dat":           "\x00\x01\x02",
+		"docs/.gitignore":   "!keep.log\n",
+		"docs/keep.log":     "kept",
+		"docs/b.txt":        "b",
+		"docs/sub/c.md":     "c",
+		"vendor/v.md":       "v",
+		"node_modules/n.md": "n",
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("..", filepath.Join(root, "docs", "sub", "up")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(wo walkOptions) []string {
+		t.Helper()
+		wo.recursive = true
+		var got []string
+		for p, err := range inputPaths([]string{root}, wo) {
+			if err != nil {
+				t.Fatalf("inputPaths error = %v", err)
+			}
+			rel, _ := filepath.Rel(root, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return got
+	}
+
+	got := walk(walkOptions{ignoreFiles: []string{".gitignore"}, exclude: []ignoreRule{{pattern: "node_modules", dirOnly: true}}})
+	want := []string{"a.md", "docs/b.txt", "docs/keep.log", "docs/sub/c.md"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("walk with .gitignore = %q, want %q", got, want)
+	}
+
+	include, _ := parseGlobs("*.md")
+	got = walk(walkOptions{include: include})
+	want = []string{"a.md", "docs/sub/c.md", "node_modules/n.md", "vendor/v.md"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("walk -include=*.md = %q, want %q", got, want)
+	}
+
+	for _, err := range inputPaths([]string{root}, walkOptions{}) {
+		if err == nil {
+			t.Fatalf("inputPaths without -r: no error for a directory")
+		}
+	}
+}
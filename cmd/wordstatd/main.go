@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -24,6 +26,20 @@ func main() {
 	enablePprof := flag.Bool("pprof", false, "enable pprof server")
 	pprofAddr := flag.String("pprof-addr", "127.0.0.1:6060", "pprof listen address")
 	maxBody := flag.Int64("max-body", wordstat.DefaultHTTPConfig.MaxBodyBytes, "number of bytes allowed in POST body")
+	maxApproxBody := flag.Int64("max-approx-body", 0, "bytes allowed in POST body with approx=true (0 = -max-body)")
+	stopwords := map[string]wordstat.Stopwords{}
+	flag.Func("stopwords", "named stopword list name=path, selectable with stopwords=name (repeatable)", func(v string) error {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("want name=path, got %q", v)
+		}
+		sw, err := wordstat.LoadStopwordsFile(path)
+		if err != nil {
+			return err
+		}
+		stopwords[name] = sw
+		return nil
+	})
 	flag.Parse()
 
 	rt, wt := *readTO, *writeTO
@@ -32,7 +48,9 @@ func main() {
 	}
 
 	mainHandler := wordstat.NewHTTPMuxWithConfig(wordstat.HTTPConfig{
-		MaxBodyBytes: *maxBody,
+		MaxBodyBytes:       *maxBody,
+		MaxApproxBodyBytes: *maxApproxBody,
+		Stopwords:          stopwords,
 	})
 	mainSrv := http.Server{
 		Addr:              *addr,
@@ -1,3 +1,5 @@
 module github.com/PetrovKirill00/go_week1
 
 go 1.25.1
+
+require golang.org/x/text v0.36.0